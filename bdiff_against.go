@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sqler/pkg"
+	"strings"
+)
+
+const (
+	// BdiffPairPosition pairs the datasources of two configs by position
+	BdiffPairPosition = "position"
+	// BdiffPairAlias pairs the datasources of two configs by alias
+	BdiffPairAlias = "alias"
+)
+
+// NewBdiffAgainstSqler connects to the datasources of the other config and returns a sqler which has
+// the datasources of both configs, the datasources of other config follow the ones of s. The sqler
+// of other config is returned too and must be closed when the comparison is done
+func NewBdiffAgainstSqler(s *Sqler, otherCfg *pkg.Config) (*Sqler, *Sqler, error) {
+	for _, ds := range otherCfg.DataSources {
+		if _, err := s.cfg.DataSourceIndex(ds.DsKey()); err == nil {
+			return nil, nil, fmt.Errorf("datasource %s is in both configs", ds.DsKey())
+		}
+	}
+	other := NewSqler(otherCfg)
+	cfg := *s.cfg
+	cfg.DataSources = append(append(make([]*pkg.DataSourceConfig, 0, len(s.dbs)+len(other.dbs)),
+		s.cfg.DataSources...), otherCfg.DataSources...)
+	return &Sqler{
+		ctx:         s.ctx,
+		cfg:         &cfg,
+		dbs:         append(append(make([]*sql.DB, 0, len(cfg.DataSources)), s.dbs...), other.dbs...),
+		tableMetas:  s.tableMetas,
+		columnMeats: s.columnMeats,
+		jobExecutor: s.jobExecutor,
+	}, other, nil
+}
+
+// bdiffAgainstPairs pairs the datasources of left config to right config by position, alias or
+// mapping like "left_1=right_1,left_2=right_2", the index of right datasource is offset by left size
+func bdiffAgainstPairs(left, right *pkg.Config, pairing string) ([][2]int, error) {
+	offset := len(left.DataSources)
+	pairs := make([][2]int, 0)
+	switch pairing {
+	case "", BdiffPairPosition:
+		if len(left.DataSources) != len(right.DataSources) {
+			return nil, fmt.Errorf("can not pair %d datasources with %d datasources by position",
+				len(left.DataSources), len(right.DataSources))
+		}
+		for i := range left.DataSources {
+			pairs = append(pairs, [2]int{i, offset + i})
+		}
+	case BdiffPairAlias:
+		for i, ds := range left.DataSources {
+			if ds.Alias == "" {
+				continue
+			}
+			for j, other := range right.DataSources {
+				if other.Alias == ds.Alias {
+					pairs = append(pairs, [2]int{i, offset + j})
+				}
+			}
+		}
+	default:
+		for _, pair := range strings.Split(pairing, ",") {
+			l, r, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid datasource pair %s, must be left=right", pair)
+			}
+			i, err := left.DataSourceIndex(strings.TrimSpace(l))
+			if err != nil {
+				return nil, err
+			}
+			j, err := right.DataSourceIndex(strings.TrimSpace(r))
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, [2]int{i, offset + j})
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no datasource is paired by %s", pairing)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sqler/pkg"
+	"testing"
+)
+
+func TestBdiffAgainstPairs(t *testing.T) {
+	as := assert.New(t)
+	left, right := pkg.NewConfig(), pkg.NewConfig()
+	left.AddDataSource(&pkg.DataSourceConfig{Alias: "a", Url: "l", Schema: "s0"})
+	left.AddDataSource(&pkg.DataSourceConfig{Alias: "b", Url: "l", Schema: "s1"})
+	right.AddDataSource(&pkg.DataSourceConfig{Alias: "b", Url: "r", Schema: "s0"})
+	right.AddDataSource(&pkg.DataSourceConfig{Alias: "a", Url: "r", Schema: "s1"})
+
+	pairs, err := bdiffAgainstPairs(left, right, BdiffPairPosition)
+	as.NoError(err)
+	as.Equal([][2]int{{0, 2}, {1, 3}}, pairs)
+	pairs, err = bdiffAgainstPairs(left, right, BdiffPairAlias)
+	as.NoError(err)
+	as.Equal([][2]int{{0, 3}, {1, 2}}, pairs)
+	pairs, err = bdiffAgainstPairs(left, right, "a=r/s0,1=0")
+	as.NoError(err)
+	as.Equal([][2]int{{0, 2}, {1, 2}}, pairs)
+	_, err = bdiffAgainstPairs(left, right, "a=x")
+	as.Error(err)
+}
+
+func TestNewBdiffAgainstSqler(t *testing.T) {
+	as := assert.New(t)
+	s := newFixtureSqler(t)
+	otherCfg := pkg.NewConfig()
+	otherCfg.AddDataSource(&pkg.DataSourceConfig{Type: "sqlite3", Url: "other", Schema: "db_01"})
+	againstSqler, other, err := NewBdiffAgainstSqler(s, otherCfg)
+	as.NoError(err)
+	as.Len(againstSqler.dbs, 4)
+	as.Equal(other.dbs[0], againstSqler.dbs[3])
+	as.NoError(other.Close())
+	as.Error(againstSqler.dbs[3].Ping())
+	as.NoError(againstSqler.dbs[0].Ping())
+
+	_, _, err = NewBdiffAgainstSqler(s, s.cfg)
+	as.Error(err)
+}
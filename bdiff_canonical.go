@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// canonicalRows returns the values of rows read in dialect d converted to the same text across
+// dialects by the database type names of columns, so that a MySQL table can be compared to the same
+// table in SQLite. Columns declared as text are kept, e.g. a SQLite TEXT column which has datetime values
+func canonicalRows(d Dialect, types []string, rows [][]string) [][]string {
+	canonical := make([][]string, len(rows))
+	for r, row := range rows {
+		canonical[r] = make([]string, len(row))
+		for i, v := range row {
+			if v != "NULL" && i < len(types) {
+				v = canonicalValue(d, types[i], v)
+			}
+			canonical[r][i] = v
+		}
+	}
+	return canonical
+}
+
+// canonicalValue renders booleans as 0 and 1, numbers without redundant zeros, datetimes as
+// "2006-01-02 15:04:05.999999999" in UTC if with zone, dates as "2006-01-02", binaries in hex
+// and json with sorted keys
+func canonicalValue(d Dialect, dbType string, v string) string {
+	t := strings.ToUpper(dbType)
+	switch {
+	case t == "BIT":
+		return d.BitValue(v)
+	case t == "BOOL" || t == "BOOLEAN":
+		switch strings.ToLower(v) {
+		case "true":
+			return "1"
+		case "false":
+			return "0"
+		}
+		return v
+	case strings.Contains(t, "INT") || t == "DECIMAL" || t == "NUMERIC" || t == "REAL" ||
+		strings.Contains(t, "FLOAT") || strings.Contains(t, "DOUBLE"):
+		return canonicalNumber(v)
+	case t == "DATE":
+		if dt, hasZone, ok := parseDatetime(v); ok {
+			if hasZone {
+				dt = dt.UTC()
+			}
+			return dt.Format(time.DateOnly)
+		}
+		return v
+	case t == "DATETIME" || t == "TIMESTAMP":
+		if dt, hasZone, ok := parseDatetime(v); ok {
+			if hasZone {
+				dt = dt.UTC()
+			}
+			return dt.Format("2006-01-02 15:04:05.999999999")
+		}
+		return v
+	case strings.Contains(t, "BLOB") || strings.Contains(t, "BINARY"):
+		return "0x" + hex.EncodeToString([]byte(v))
+	case t == "JSON":
+		return canonicalJson(v)
+	}
+	return v
+}
+
+// canonicalNumber removes the redundant zeros and exponent, "1.50", "01.5" and "15e-1" are "1.5"
+func canonicalNumber(v string) string {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(v))
+	if !ok {
+		return v
+	}
+	if rat.IsInt() {
+		return rat.Num().String()
+	}
+	// The exact digits of decimal text, e.g. decimal(30,20) and 1e-10
+	prec, exact := rat.FloatPrec()
+	if !exact {
+		return v
+	}
+	return rat.FloatString(prec)
+}
+
+// mixedDataSourceTypes reports whether the datasources of dbIds are of different types
+func mixedDataSourceTypes(s *Sqler, dbIds ...int) bool {
+	for _, dbIdx := range dbIds {
+		if s.cfg.DataSources[dbIdx].Type != s.cfg.DataSources[dbIds[0]].Type {
+			return true
+		}
+	}
+	return false
+}
+
+// queryRows queries the rows of table, the values are canonical if the table is compared across dialects
+func (t *bdiffTable) queryRows(db sqlQueryer, dialect Dialect, query string) ([]string, []string, [][]string, error) {
+	columns, types, rows, _, err := t.queryRawRows(db, dialect, query)
+	return columns, types, rows, err
+}
+
+// queryRawRows queries the rows of table like queryRows, and the raw rows as read which the repair
+// SQL is built from. Raw rows are the rows if the table is not canonical
+func (t *bdiffTable) queryRawRows(db sqlQueryer, dialect Dialect, query string) ([]string, []string, [][]string, [][]string, error) {
+	columns, types, rows, err := queryAsStringWithTypes(db, query)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if !t.canonical {
+		return columns, types, rows, rows, nil
+	}
+	return columns, types, canonicalRows(dialect, types, rows), rows, nil
+}
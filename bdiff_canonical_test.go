@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalValue(t *testing.T) {
+	as := assert.New(t)
+	cases := []struct {
+		mysqlType  string
+		mysql      string
+		sqliteType string
+		sqlite     string
+	}{
+		{"TINYINT", "1", "BOOLEAN", "true"},
+		{"BIT", "\x01", "BOOLEAN", "1"},
+		{"BIT", "\x01", "BIT", "1"},
+		{"BIT", "1", "BIT", "49"},
+		{"BIT", "\x01\x00", "BIT", "256"},
+		{"DECIMAL", "1.50", "NUMERIC", "1.5"},
+		{"BIGINT", "2", "REAL", "2.0"},
+		{"DATETIME", "2024-01-02 03:04:05", "DATETIME", "2024-01-02T03:04:05Z"},
+		{"DATETIME", "2024-01-02 03:04:05.100000", "TIMESTAMP", "2024-01-02T03:04:05.1Z"},
+		{"DATE", "2024-01-02", "DATE", "2024-01-02T00:00:00Z"},
+		{"VARBINARY", "\x01\x02", "BLOB", "\x01\x02"},
+		{"JSON", `{"b": 1, "a": [1, 2]}`, "JSON", `{"a":[1,2],"b":1}`},
+	}
+	for _, c := range cases {
+		as.Equal(canonicalValue(mysqlDialect{}, c.mysqlType, c.mysql), canonicalValue(sqliteDialect{}, c.sqliteType, c.sqlite), c.mysqlType)
+	}
+	as.Equal("0x0102", canonicalValue(sqliteDialect{}, "BLOB", "\x01\x02"))
+	as.Equal("abc", canonicalValue(sqliteDialect{}, "TEXT", "abc"))
+
+	// SQLite returns the declared type and the integer of a BIT column
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "bit.sqlite"))
+	as.NoError(err)
+	defer db.Close()
+	_, err = db.Exec("create table t (b bit(8)); insert into t values (1), (5), (null)")
+	as.NoError(err)
+	_, types, rows, err := (&bdiffTable{canonical: true}).queryRows(db, sqliteDialect{}, "select b from t")
+	as.NoError(err)
+	as.Equal([]string{"BIT"}, types)
+	as.Equal([][]string{{"1"}, {"5"}, {"NULL"}}, rows)
+
+	// The repair SQL is built from the raw values
+	_, err = db.Exec("create table r (id int, v blob); insert into r values (1, x'0102')")
+	as.NoError(err)
+	_, _, rows, raws, err := (&bdiffTable{canonical: true}).queryRawRows(db, sqliteDialect{}, "select id, v from r")
+	as.NoError(err)
+	as.Equal([][]string{{"1", "0x0102"}}, rows)
+	row := rowResultToMap(rows, raws, []int{0})["1"]
+	as.Equal([]string{"1", "0x0102"}, row.cols)
+	as.Equal(`INSERT INTO "r"("id","v") VALUES ('1','`+"\x01\x02"+`')`, generateInsertSql(sqliteDialect{}, "r", []string{"id", "v"}, row.raw))
+
+	for v, want := range map[string]string{
+		"1.50":                   "1.5",
+		"01.5":                   "1.5",
+		"15e-1":                  "1.5",
+		"1e-10":                  "0.0000000001",
+		"2.5E-12":                "0.0000000000025",
+		"1.5e3":                  "1500",
+		"-0.010":                 "-0.01",
+		"0.12345678901234567890": "0.1234567890123456789",
+		"abc":                    "abc",
+	} {
+		as.Equal(want, canonicalNumber(v), v)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sqler/pkg"
+	"strings"
+)
+
+// newBdiffCsvBase creates the base of table from a csv extract with header, the csv headers are
+// mapped to columns by opts.CsvMapping and only the mapped columns are compared
+func newBdiffCsvBase(sqler *Sqler, table *bdiffTable, opts *BdiffOptions, typeDbIdx int, jobSize int) (*bdiffBase, error) {
+	file, err := os.Open(opts.CsvBase)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(file)
+	headers, err := reader.Read()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to read header of %s: %w", opts.CsvBase, err)
+	}
+
+	// Map csv headers to columns, the headers mapped to "" are ignored
+	columns := make([]string, 0, len(headers))
+	headerIdx := make([]int, 0, len(headers))
+	for i, header := range headers {
+		column, ok := opts.CsvMapping[header]
+		if !ok {
+			column = header
+		}
+		if column == "" {
+			continue
+		}
+		columns = append(columns, column)
+		headerIdx = append(headerIdx, i)
+	}
+	if len(columns) == 0 {
+		_ = file.Close()
+		return nil, fmt.Errorf("no column is mapped from %s", opts.CsvBase)
+	}
+	table.columns = columns
+	if len(opts.CsvKeys) > 0 {
+		table.keyCols = opts.CsvKeys
+	}
+
+	b := newBdiffBase(sqler, table, typeDbIdx, opts.MaxRow, jobSize, false)
+	b.name = bdiffCsvBaseName(opts.CsvBase)
+	b.label = "CSV"
+	b.loader = func() error {
+		defer file.Close()
+		return b.loadFromCsv(reader, columns, headerIdx)
+	}
+	return b, nil
+}
+
+// loadFromCsv reads the rest rows of csv extract, "NULL" is the null value like query results
+func (b *bdiffBase) loadFromCsv(reader *csv.Reader, columns []string, headerIdx []int) error {
+	printer.Info(fmt.Sprintf("[%s] Loading %s data: %s", pkg.Now(), b.label, b.table.name))
+	rows := make([][]string, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		row := make([]string, len(headerIdx))
+		for i, idx := range headerIdx {
+			row[i] = record[idx]
+		}
+		rows = append(rows, row)
+		if b.maxRow > 0 && b.maxRow < len(rows) {
+			b.skipReason = fmt.Sprintf("too many data in csv (> %d)", b.maxRow)
+			return nil
+		}
+	}
+
+	// Column types of the table are used to match normalizer rules
+	dialect := b.sqler.Dialect(b.dbIdx)
+	_, types, _, err := queryAsStringWithTypes(b.sqler.Reader(b.dbIdx), dialect.Paging(b.table.query(dialect), 0, 0))
+	if err != nil {
+		return fmt.Errorf("failed to query columns %s mapped from csv: %w", strings.Join(columns, ","), err)
+	}
+	cols, err := b.table.newBdiffCols(b.sqler.cfg.CommandsConfig, columns, types)
+	if err != nil {
+		return err
+	}
+	// A key of more than one row can not be compared, rows are counted in file with the header as row 1
+	rowMap := rowResultToMap(rows, nil, cols.keyIdx)
+	if len(rowMap) < len(rows) {
+		seen := make(map[string]bool, len(rows))
+		for i, row := range rows {
+			key := rowKey(row, cols.keyIdx)
+			if seen[key] {
+				return fmt.Errorf("duplicate key %s at row %d of csv", strings.ReplaceAll(key, "\x1f", ","), i+2)
+			}
+			seen[key] = true
+		}
+	}
+	if b.csvFile, err = openBdiffCsv(b.csvFileName, b.state.Resumed(), bdiffCsvHeader(columns)); err != nil {
+		return err
+	}
+	b.columns = columns
+	b.rowMap = rowMap
+	b.cols = cols
+	return nil
+}
+
+// bdiffCsvBaseName is the base name of csv extract in bdiff state
+func bdiffCsvBaseName(fileName string) string {
+	return "CSV:" + fileName
+}
+
+// parseCsvMapping parses mapping like "csv_header_1=column_1,csv_header_2=column_2,ignored_header="
+func parseCsvMapping(mapping string) (map[string]string, error) {
+	m := make(map[string]string)
+	if strings.TrimSpace(mapping) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(mapping, ",") {
+		header, column, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid csv mapping %s, must be header=column", pair)
+		}
+		m[strings.TrimSpace(header)] = strings.TrimSpace(column)
+	}
+	return m, nil
+}
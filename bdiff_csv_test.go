@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestParseCsvMapping(t *testing.T) {
+	as := assert.New(t)
+	m, err := parseCsvMapping("ID=id, Name = a1,note=")
+	as.NoError(err)
+	as.Equal(map[string]string{"ID": "id", "Name": "a1", "note": ""}, m)
+	m, err = parseCsvMapping(" ")
+	as.NoError(err)
+	as.Empty(m)
+	_, err = parseCsvMapping("ID")
+	as.Error(err)
+}
+
+func TestBdiffCsvBase(t *testing.T) {
+	as := assert.New(t)
+	s := newFixtureSqler(t)
+	// run compares the csv to table a of datasource base and returns the types of rows by key
+	run := func(content string, mapping map[string]string, keys []string) (map[string][]string, error) {
+		as.NoError(os.RemoveAll("bdiff"))
+		as.NoError(os.WriteFile("a.csv", []byte(content), 0644))
+		job := NewBdiffJob(s, []string{"a"}, &BdiffOptions{CsvBase: "a.csv", CsvMapping: mapping, CsvKeys: keys, Targets: []string{"base"}})
+		job.Exec()
+		if job.Error() != nil {
+			return nil, job.Error()
+		}
+		file, err := os.Open("bdiff/a.csv")
+		as.NoError(err)
+		defer file.Close()
+		records, err := csv.NewReader(file).ReadAll()
+		as.NoError(err)
+		types := make(map[string][]string)
+		for _, record := range records[1:] {
+			types[record[4]] = append(types[record[4]], record[1]+" "+record[2])
+		}
+		return types, nil
+	}
+
+	// Headers are mapped to columns, the unmapped header is the column and the header mapped to "" is ignored
+	mapping := map[string]string{"ID": "id", "A1": "a1", "note": ""}
+	types, err := run("ID,A1,a2,note\n1,a1_1,a2_1,x\n2,a1_2,changed,x\n3,a1_3,a2_3,x\n9,a1_9,a2_9,x\n", mapping, nil)
+	as.NoError(err)
+	as.Equal(map[string][]string{
+		"2": {"CSV DIFF", "/db_base DIFF"},
+		"5": {"/db_base EXTRA"},
+		"9": {"/db_base MISSING"},
+	}, types)
+
+	// Rows are matched by the keys of csv
+	types, err = run("ID,A1,a2\n10,a1_1,a2_1\n2,a1_2,a2_2\n", mapping, []string{"a1"})
+	as.NoError(err)
+	as.Equal(map[string][]string{
+		"10": {"CSV DIFF"},
+		"1":  {"/db_base DIFF"},
+		"3":  {"/db_base EXTRA"},
+		"5":  {"/db_base EXTRA"},
+	}, types)
+
+	// The mapped column is not in table
+	_, err = run("ID,A1,a2\n1,a1_1,a2_1\n", map[string]string{"ID": "id", "A1": "a1", "a2": "missing_col"}, nil)
+	as.ErrorContains(err, "bdiff jobs failed")
+	summary, err := os.ReadFile("bdiff/summary.json")
+	as.NoError(err)
+	as.Contains(string(summary), "failed to query columns id,a1,missing_col mapped from csv")
+	// A key of more than one row
+	_, err = run("ID,A1,a2\n1,a1_1,a2_1\n2,a1_2,a2_2\n1,a1_1,a2_1\n", mapping, nil)
+	as.ErrorContains(err, "bdiff jobs failed")
+	summary, err = os.ReadFile("bdiff/summary.json")
+	as.NoError(err)
+	as.Contains(string(summary), "duplicate key 1 at row 4 of csv")
+}
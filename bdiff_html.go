@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"html/template"
+	"io"
+	"os"
+	"sqler/pkg"
+)
+
+const (
+	bdiffHtmlFileName = "bdiff/report.html"
+	// bdiffHtmlMaxRows limits the rows of each csv file shown in html report
+	bdiffHtmlMaxRows = 1000
+)
+
+type bdiffHtmlCell struct {
+	Base  string
+	Value string
+	Diff  bool
+}
+
+type bdiffHtmlRow struct {
+	DataSource string
+	Type       string
+	Cells      []bdiffHtmlCell
+}
+
+type bdiffHtmlTable struct {
+	CsvFile   string
+	Columns   []string
+	Rows      []bdiffHtmlRow
+	Truncated bool
+}
+
+var bdiffHtmlTemplate = template.Must(template.New("bdiff").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>bdiff report</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; margin: 20px; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 3px 6px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+tr.MISSING td { background: #fff3cd; }
+tr.EXTRA td { background: #d1ecf1; }
+tr.TIE td { background: #e2e3e5; }
+tr.DIFF_TABLE td { background: #f8d7da; }
+td.diff { background: #f8d7da; }
+td.diff del { color: #721c24; display: block; }
+td.diff ins { color: #155724; display: block; text-decoration: none; font-weight: bold; }
+.same { color: #999; }
+</style>
+</head>
+<body>
+<h1>bdiff report</h1>
+<p>Generated at {{.Now}}</p>
+<h2>Summary</h2>
+<table>
+<tr><th>Table</th><th>Base</th><th>DataSource</th><th>BaseRows</th><th>Rows</th><th>Missing</th><th>Extra</th><th>Diff</th><th>Normalized</th><th>Tie</th><th>DiffTable</th><th>Skipped</th><th>Error</th></tr>
+{{range .Entries}}<tr><td>{{.Table}}</td><td>{{.Base}}</td><td>{{.DataSource}}</td><td>{{.BaseRows}}</td><td>{{.Rows}}</td><td>{{.Missing}}</td><td>{{.Extra}}</td><td>{{.Diff}}</td><td>{{.Normalized}}</td><td>{{.Tie}}</td><td>{{.DiffTable}}</td><td>{{.Skipped}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{range .Tables}}<h2>{{.CsvFile}}</h2>
+<table>
+<tr><th>DataSource</th><th>Type</th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr class="{{.Type}}"><td>{{.DataSource}}</td><td>{{.Type}}</td>{{range .Cells}}{{if .Diff}}<td class="diff"><del>{{.Base}}</del><ins>{{.Value}}</ins></td>{{else if eq .Value ""}}<td class="same">{{.Base}}</td>{{else}}<td>{{.Value}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+{{if .Truncated}}<p>Only the first {{$.MaxRows}} rows are shown.</p>{{end}}
+{{end}}</body>
+</html>
+`))
+
+// writeBdiffHtml renders the summary and the differences in csv files of summary entries
+func writeBdiffHtml(fileName string, summary *bdiffSummary) error {
+	summary.mu.Lock()
+	entries := summary.entries
+	summary.mu.Unlock()
+	tables := make([]*bdiffHtmlTable, 0)
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.CsvFile == "" || seen[e.CsvFile] {
+			continue
+		}
+		seen[e.CsvFile] = true
+		table, err := readBdiffHtmlTable(e.CsvFile)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		tables = append(tables, table)
+	}
+
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return bdiffHtmlTemplate.Execute(file, map[string]any{
+		"Now":     pkg.Now(),
+		"Entries": entries,
+		"Tables":  tables,
+		"MaxRows": bdiffHtmlMaxRows,
+	})
+}
+
+// readBdiffHtmlTable reads a bdiff csv file, the DIFF records of base and target are merged into one row
+func readBdiffHtmlTable(csvFileName string) (*bdiffHtmlTable, error) {
+	file, err := os.Open(csvFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	table := &bdiffHtmlTable{CsvFile: csvFileName, Columns: header[4:]}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(table.Rows) >= bdiffHtmlMaxRows {
+			table.Truncated = true
+			break
+		}
+		row := bdiffHtmlRow{DataSource: record[1], Type: record[2]}
+		switch record[2] {
+		case "NORMALIZED":
+			continue
+		case "DIFF":
+			// The record of base is followed by the record of target, same values of target are "/"
+			target, err := reader.Read()
+			if err != nil {
+				return nil, err
+			}
+			row.DataSource = target[1]
+			// Key values are kept in target to identify the row
+			for i, v := range record[4:] {
+				if i+4 < len(target) && target[i+4] != "/" && target[i+4] != v {
+					row.Cells = append(row.Cells, bdiffHtmlCell{Base: v, Value: target[i+4], Diff: true})
+				} else {
+					row.Cells = append(row.Cells, bdiffHtmlCell{Base: v})
+				}
+			}
+		default:
+			for _, v := range record[4:] {
+				row.Cells = append(row.Cells, bdiffHtmlCell{Value: v})
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
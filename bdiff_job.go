@@ -1,46 +1,214 @@
 package main
 
 import (
-	"database/sql"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
 	"os"
+	"slices"
+	"sort"
 	"sqler/pkg"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-func NewBdiffJob(sqler *Sqler, schemas []string, maxRow int, batchRow int) Job {
+const (
+	// BdiffModeBase compares every datasource to the base datasource
+	BdiffModeBase = "base"
+	// BdiffModePairwise compares every two datasources
+	BdiffModePairwise = "pairwise"
+	// BdiffModeMajority compares every datasource to the consensus row of all datasources
+	BdiffModeMajority = "majority"
+)
+
+// BdiffOptions are the options of BdiffJob
+type BdiffOptions struct {
+	// MaxRow skips tables which have more rows, 0 means no limit
+	MaxRow int
+	// BatchRow is the number of rows queried from target datasource at once, 0 means no limit
+	BatchRow int
+	// Parallel is the max number of jobs running at the same time
+	Parallel int
+	// Base is the alias, DsKey or index of the base datasource, the first datasource if empty
+	Base string
+	// Mode is one of BdiffModeBase, BdiffModePairwise and BdiffModeMajority
+	Mode string
+	// Targets are the alias, DsKey or index of datasources to compare, all datasources if empty
+	Targets []string
+	// CsvBase is the csv file used as the base instead of datasource
+	CsvBase string
+	// CsvMapping maps csv headers to columns, headers not in it are used as column names
+	CsvMapping map[string]string
+	// CsvKeys are the key columns of csv base, the keys of bdiff table config are used if empty
+	CsvKeys []string
+	// Resume skips the units finished by last bdiff and appends to the existing reports
+	Resume bool
+	// Html generates a html report which shows the differences side by side
+	Html bool
+	// Pairs are the base and target datasources compared, used by bdiff against other config
+	Pairs [][2]int
+}
+
+func NewBdiffJob(sqler *Sqler, schemas []string, opts *BdiffOptions) Job {
 	if len(schemas) == 0 {
 		schemas = sqler.cfg.CommandsConfig.BdiffSchemas
 	}
-	skipColsMap := make(map[string]bool, len(sqler.cfg.CommandsConfig.BdiffSchemas))
-	for _, skipCol := range sqler.cfg.CommandsConfig.BdiffSkipCols {
-		skipColsMap[skipCol] = true
+	if len(schemas) == 0 {
+		for table := range sqler.cfg.CommandsConfig.BdiffTables {
+			schemas = append(schemas, table)
+		}
+		sort.Strings(schemas)
+	}
+	if opts.Parallel <= 0 {
+		opts.Parallel = 1
+	}
+	if opts.Mode == "" {
+		opts.Mode = BdiffModeBase
 	}
 	return &BdiffJob{
-		sqler:       sqler,
-		schemas:     schemas,
-		maxRow:      maxRow,
-		skipColsMap: skipColsMap,
-		batchRow:    batchRow,
-		BaseJob:     NewBaseJob(new(JobCtx)),
+		sqler:   sqler,
+		schemas: schemas,
+		opts:    opts,
+		BaseJob: NewBaseJob(new(JobCtx)),
 	}
 }
 
+// BdiffJob compares tables of datasources, each (table, base, target) is compared by
+// a BdiffTableJob, or each table is compared by a BdiffMajorityJob in majority mode
 type BdiffJob struct {
-	sqler       *Sqler
-	schemas     []string
-	maxRow      int
-	skipColsMap map[string]bool
-	batchRow    int
+	sqler   *Sqler
+	schemas []string
+	opts    *BdiffOptions
 	*BaseJob
 }
 
+// bdiffTable is the resolved bdiff settings of one table
+type bdiffTable struct {
+	name  string
+	where string
+	// columns are selected, all columns if empty
+	columns     []string
+	keyCols     []string
+	skipColsMap map[string]bool
+	// canonical converts values to the same text if the table is compared across dialects
+	canonical bool
+}
+
+func newBdiffTable(cmdCfg *pkg.CommandsConfig, table string) *bdiffTable {
+	tableCfg := cmdCfg.BdiffTable(table)
+	skipColsMap := make(map[string]bool, len(cmdCfg.BdiffSkipCols)+len(tableCfg.SkipCols))
+	for _, skipCol := range cmdCfg.BdiffSkipCols {
+		skipColsMap[skipCol] = true
+	}
+	for _, skipCol := range tableCfg.SkipCols {
+		skipColsMap[skipCol] = true
+	}
+	where := ""
+	if tableCfg.Where != "" {
+		where = " where " + tableCfg.Where
+	}
+	t := &bdiffTable{
+		name:        table,
+		where:       where,
+		columns:     tableCfg.Columns,
+		keyCols:     tableCfg.Keys,
+		skipColsMap: skipColsMap,
+	}
+	return t
+}
+
+// settingsHash returns the hash of where, columns and keys which select the rows of chunks
+func (t *bdiffTable) settingsHash() string {
+	settings := strings.Join([]string{t.where, strings.Join(t.columns, ","), strings.Join(t.keyCols, ",")}, "\x00")
+	hash := sha256.Sum256([]byte(settings))
+	return hex.EncodeToString(hash[:8])
+}
+
+// query returns the query of the selected columns in dialect
+func (t *bdiffTable) query(dialect Dialect) string {
+	cols := "*"
+	if len(t.columns) > 0 {
+		cols = quoteIdents(dialect, t.columns)
+	}
+	return fmt.Sprintf("select %s from %s%s", cols, dialect.QuoteIdent(t.name), t.where)
+}
+
+func (t *bdiffTable) countQuery(dialect Dialect) string {
+	return fmt.Sprintf("select count(*) from %s%s", dialect.QuoteIdent(t.name), t.where)
+}
+
+// orderBy returns the quoted key columns, the first column is the key if keys are not configured
+func (t *bdiffTable) orderBy(db sqlQueryer, dialect Dialect) (string, error) {
+	keyCols := t.keyCols
+	switch {
+	case len(keyCols) > 0:
+	case len(t.columns) > 0:
+		keyCols = t.columns[:1]
+	default:
+		columns, _, _, err := queryAsStringWithTypes(db, dialect.Paging(t.query(dialect), 1, 0))
+		if err != nil {
+			return "", err
+		}
+		keyCols = columns[:1]
+	}
+	return quoteIdents(dialect, keyCols), nil
+}
+
+// keyIndexes returns the index of key columns in columns
+func (t *bdiffTable) keyIndexes(columns []string) ([]int, error) {
+	if len(t.keyCols) == 0 {
+		return []int{0}, nil
+	}
+	keyIdx := make([]int, 0, len(t.keyCols))
+	for _, keyCol := range t.keyCols {
+		idx := slices.Index(columns, keyCol)
+		if idx < 0 {
+			return nil, fmt.Errorf("key column %s not found in table %s", keyCol, t.name)
+		}
+		keyIdx = append(keyIdx, idx)
+	}
+	return keyIdx, nil
+}
+
+// bdiffCols describes how the columns of a table are compared
+type bdiffCols struct {
+	skipCol []bool
+	keyIdx  []int
+	rules   [][]bdiffRule
+}
+
+func (t *bdiffTable) newBdiffCols(cmdCfg *pkg.CommandsConfig, columns []string, types []string) (*bdiffCols, error) {
+	// Generate skipping cols index
+	skipCol := make([]bool, len(columns))
+	for i, column := range columns {
+		if t.skipColsMap[column] {
+			skipCol[i] = true
+		}
+	}
+	keyIdx, err := t.keyIndexes(columns)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseBdiffRules(cmdCfg.BdiffNormalizers, t.name, columns, types)
+	if err != nil {
+		return nil, err
+	}
+	return &bdiffCols{
+		skipCol: skipCol,
+		keyIdx:  keyIdx,
+		rules:   rules,
+	}, nil
+}
+
 type dataRow struct {
-	cols     []string
-	compared bool
+	cols []string
+	// raw are the values as read, cols are canonical of them if compared across dialects
+	raw []string
 }
 
 func (job *BdiffJob) Exec() {
@@ -49,160 +217,454 @@ func (job *BdiffJob) Exec() {
 			return
 		}
 	}
-	baseDb := job.sqler.dbs[0]
-	// Compare schemas
-	for sid, schema := range job.schemas {
-		// csv file
-		csvFileName := fmt.Sprintf("bdiff/%s.csv", schema)
-		file, err := os.OpenFile(csvFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
-		if err != nil {
-			panic(err)
-		}
-		csvFile := csv.NewWriter(file)
-
-		printer.Info(fmt.Sprintf("[%s] Loading BASE data: %s", pkg.Now(), schema))
-		// Skip if too many data
-		_ = baseDb.Ping()
-		rows, err := baseDb.Query(fmt.Sprintf("select count(*) from %s", schema))
-		if job.RecordError(err) {
+	dbSize := len(job.sqler.dbs)
+	if dbSize < 2 && job.opts.CsvBase == "" {
+		job.RecordError(errors.New("bdiff needs at least two datasources"))
+		return
+	}
+	baseIdx := 0
+	if job.opts.Base != "" {
+		var err error
+		if baseIdx, err = job.sqler.cfg.DataSourceIndex(job.opts.Base); job.RecordError(err) {
 			return
 		}
-		_, result, err := convertSqlResults(rows)
+	}
+	dbIds := make([]int, 0, dbSize)
+	for _, target := range job.opts.Targets {
+		dbIdx, err := job.sqler.cfg.DataSourceIndex(target)
 		if job.RecordError(err) {
 			return
 		}
-		rowNumber, err := strconv.Atoi(result[0][0])
-		if job.RecordError(err) {
-			return
+		dbIds = append(dbIds, dbIdx)
+	}
+	if len(dbIds) == 0 {
+		for dbIdx := range job.sqler.dbs {
+			dbIds = append(dbIds, dbIdx)
 		}
-		if job.maxRow > 0 && job.maxRow < rowNumber {
-			printer.Info(fmt.Sprintf("[%s] Skip comparsion because of too many data in %s (%d > %d)\n", pkg.Now(), schema, rowNumber, job.maxRow))
-			continue
+	}
+	if job.opts.CsvBase != "" && len(job.schemas) != 1 {
+		job.RecordError(errors.New("bdiff with csv base compares exactly one table"))
+		return
+	}
+	// The chunks of tables are decided by the batch row, where, columns and keys
+	settings := []string{"batch row\t" + strconv.Itoa(job.opts.BatchRow)}
+	for _, schema := range job.schemas {
+		table := newBdiffTable(job.sqler.cfg.CommandsConfig, schema)
+		settings = append(settings, fmt.Sprintf("where/columns/keys of %s\t%s", schema, table.settingsHash()))
+	}
+	state, err := openBdiffState(bdiffStateFileName, job.opts.Resume, settings)
+	if job.RecordError(err) {
+		return
+	}
+	defer func() {
+		job.RecordError(state.Close())
+	}()
+	// Values are canonical if datasources of different types are compared
+	canonical := mixedDataSourceTypes(job.sqler, append([]int{baseIdx}, dbIds...)...)
+	for _, pair := range job.opts.Pairs {
+		canonical = canonical || mixedDataSourceTypes(job.sqler, pair[0], pair[1])
+	}
+	summary := new(bdiffSummary)
+	// Targets of table whose comparison to base is not finished
+	skipped := 0
+	pending := func(table string, base string, targets []int) []int {
+		return slices.DeleteFunc(slices.Clone(targets), func(dbIdx int) bool {
+			unit := bdiffUnit{Table: table, Base: base, Target: job.sqler.cfg.DataSources[dbIdx].DsKey()}
+			if state.Done(unit, bdiffChunkEnd) {
+				summary.Entry(unit.Table, unit.Base, unit.Target, "").Skipped = "finished by last bdiff"
+				skipped++
+				return true
+			}
+			return false
+		})
+	}
+	newTableJob := func(base *bdiffBase, dbIdx int, sid int) Job {
+		entry := summary.Entry(base.table.name, base.name, job.sqler.cfg.DataSources[dbIdx].DsKey(), base.csvFileName)
+		entry.SnapshotAt = job.sqler.SnapshotAt(dbIdx)
+		if base.loader == nil {
+			entry.BaseSnapshotAt = job.sqler.SnapshotAt(base.dbIdx)
 		}
+		return NewBdiffTableJob(job.sqler, base, dbIdx, sid+1, len(job.schemas), job.opts.BatchRow, entry)
+	}
 
-		// Get base data
-		query := "select * from " + schema
-		rawBaseRows, err := baseDb.Query(query)
-		if job.RecordError(err) {
-			return
+	// Jobs are spread over job.opts.Parallel lanes whatever the table and datasource are, the
+	// connections are pooled and a snapshot of datasource runs one query at a time by its lock
+	laneSize := job.opts.Parallel
+	jobExecutor := NewJobExecutorWithCache(laneSize, len(job.schemas)*dbSize)
+	jobExecutor.Start()
+	jobs := make([]Job, 0, len(job.schemas)*dbSize)
+	submit := func(j Job) {
+		jobExecutor.Submit(j, len(jobs)%laneSize)
+		jobs = append(jobs, j)
+	}
+	for sid, schema := range job.schemas {
+		table := newBdiffTable(job.sqler.cfg.CommandsConfig, schema)
+		table.canonical = canonical
+		switch {
+		case len(job.opts.Pairs) > 0:
+			// A base datasource may be paired with more than one target
+			bases := make([]int, 0, len(job.opts.Pairs))
+			pairTargets := make(map[int][]int)
+			for _, pair := range job.opts.Pairs {
+				if _, ok := pairTargets[pair[0]]; !ok {
+					bases = append(bases, pair[0])
+				}
+				pairTargets[pair[0]] = append(pairTargets[pair[0]], pair[1])
+			}
+			for _, baseIdx := range bases {
+				targets := pending(schema, job.sqler.cfg.DataSources[baseIdx].DsKey(), pairTargets[baseIdx])
+				if len(targets) == 0 {
+					continue
+				}
+				base := newBdiffBase(job.sqler, table, baseIdx, job.opts.MaxRow, len(targets), true)
+				base.state = state
+				for _, dbIdx := range targets {
+					submit(newTableJob(base, dbIdx, sid))
+				}
+			}
+		case job.opts.CsvBase != "":
+			targets := pending(schema, bdiffCsvBaseName(job.opts.CsvBase), dbIds)
+			if len(targets) == 0 {
+				break
+			}
+			base, err := newBdiffCsvBase(job.sqler, table, job.opts, targets[0], len(targets))
+			if job.RecordError(err) {
+				break
+			}
+			base.state = state
+			for _, dbIdx := range targets {
+				submit(newTableJob(base, dbIdx, sid))
+			}
+		case job.opts.Mode == BdiffModeBase:
+			targets := slices.DeleteFunc(slices.Clone(dbIds), func(dbIdx int) bool { return dbIdx == baseIdx })
+			targets = pending(schema, job.sqler.cfg.DataSources[baseIdx].DsKey(), targets)
+			if len(targets) == 0 {
+				break
+			}
+			base := newBdiffBase(job.sqler, table, baseIdx, job.opts.MaxRow, len(targets), false)
+			base.state = state
+			for _, dbIdx := range targets {
+				submit(newTableJob(base, dbIdx, sid))
+			}
+		case job.opts.Mode == BdiffModePairwise:
+			for i, baseIdx := range dbIds[:len(dbIds)-1] {
+				targets := pending(schema, job.sqler.cfg.DataSources[baseIdx].DsKey(), dbIds[i+1:])
+				if len(targets) == 0 {
+					continue
+				}
+				base := newBdiffBase(job.sqler, table, baseIdx, job.opts.MaxRow, len(targets), true)
+				base.state = state
+				for _, dbIdx := range targets {
+					submit(newTableJob(base, dbIdx, sid))
+				}
+			}
+		case job.opts.Mode == BdiffModeMajority:
+			if state.Done(majorityUnit(schema), bdiffChunkEnd) {
+				summary.Entry(schema, BdiffModeMajority, "*", "").Skipped = "finished by last bdiff"
+				skipped++
+				break
+			}
+			for _, dbIdx := range dbIds {
+				entry := summary.Entry(schema, BdiffModeMajority, job.sqler.cfg.DataSources[dbIdx].DsKey(), fmt.Sprintf("bdiff/%s.csv", schema))
+				entry.SnapshotAt = job.sqler.SnapshotAt(dbIdx)
+			}
+			submit(NewBdiffMajorityJob(job.sqler, table, dbIds, job.opts.MaxRow, sid+1, len(job.schemas), state, summary))
+		default:
+			job.RecordError(fmt.Errorf("unknown bdiff mode %s", job.opts.Mode))
 		}
-		baseColumns, baseRows, err := convertSqlResults(rawBaseRows)
-		if job.RecordError(err) {
+		if job.Error() != nil {
+			break
+		}
+	}
+	jobExecutor.Shutdown(true)
+
+	failed := 0
+	for _, tableJob := range jobs {
+		if tableJob.Error() != nil {
+			failed++
+		}
+	}
+	if job.RecordError(summary.Save(bdiffSummaryFileName)) {
+		return
+	}
+	printer.Info(fmt.Sprintf("[%s] Saved summary to %s.csv and %s.json", pkg.Now(), bdiffSummaryFileName, bdiffSummaryFileName))
+	if job.opts.Html {
+		if job.RecordError(writeBdiffHtml(bdiffHtmlFileName, summary)) {
 			return
 		}
+		printer.Info(fmt.Sprintf("[%s] Saved html report to %s", pkg.Now(), bdiffHtmlFileName))
+	}
+	if skipped > 0 {
+		printer.Info(fmt.Sprintf("[%s] Skipped %d finished bdiff jobs", pkg.Now(), skipped))
+	}
+	if failed > 0 {
+		job.RecordError(fmt.Errorf("%d/%d bdiff jobs failed, see %s and resume by -bdiff-resume", failed, len(jobs), bdiffStateFileName))
+	}
+	printer.Info(fmt.Sprintf("[%s] All bdiff jobs are done", pkg.Now()))
+}
 
-		mustWriteToCsv(csvFile, baseColumns, "Table", "DataSource", "Type", "SQL")
+// bdiffBase is the base data of a table, loaded once and shared by all target datasources
+type bdiffBase struct {
+	sqler *Sqler
+	table *bdiffTable
+	dbIdx int
+	// name identifies the base in bdiff state
+	name        string
+	label       string
+	state       *bdiffState
+	maxRow      int
+	once        sync.Once
+	remainJobs  atomic.Int32
+	csvFileName string
+	csvFile     *syncCsvWriter
+	// loader loads the base data, loadFromDb if nil
+	loader     func() error
+	columns    []string
+	rowMap     map[string]*dataRow
+	cols       *bdiffCols
+	skipReason string
+	err        error
+}
 
-		// Generate skipping cols index
-		skipCol := make([]bool, len(baseColumns))
-		for i, column := range baseColumns {
-			if job.skipColsMap[column] {
-				skipCol[i] = true
-			}
+// newBdiffBase creates the base of table at datasource dbIdx, the base datasource is named in
+// the csv file name and labels if named is true
+func newBdiffBase(sqler *Sqler, table *bdiffTable, dbIdx int, maxRow int, jobSize int, named bool) *bdiffBase {
+	b := &bdiffBase{
+		sqler:       sqler,
+		table:       table,
+		dbIdx:       dbIdx,
+		name:        sqler.cfg.DataSources[dbIdx].DsKey(),
+		label:       "BASE",
+		maxRow:      maxRow,
+		csvFileName: fmt.Sprintf("bdiff/%s.csv", table.name),
+	}
+	if named {
+		b.label = "BASE " + sqler.cfg.DataSources[dbIdx].Name()
+		b.csvFileName = fmt.Sprintf("bdiff/%s.%d.csv", table.name, dbIdx)
+	}
+	b.remainJobs.Store(int32(jobSize))
+	return b
+}
+
+// load queries the base data at the first call, later calls wait and reuse the result
+func (b *bdiffBase) load() error {
+	b.once.Do(func() {
+		if b.loader != nil {
+			b.err = b.loader()
+		} else {
+			b.err = b.loadFromDb()
 		}
-		// Base row map
-		baseRowMap := rowResultToMap(baseRows)
+	})
+	return b.err
+}
 
-		// Compare to other db
-		for dbIdx, db := range job.sqler.dbs {
-			if dbIdx == 0 {
-				continue
-			}
-			printer.Info(fmt.Sprintf("[%s] Comparing table %s (%d/%d) at db %s (%d/%d) ... ", pkg.Now(),
-				schema, sid+1, len(job.schemas), job.sqler.cfg.DataSources[dbIdx].DsKey(), dbIdx, len(job.sqler.dbs)-1))
-			dsKey := job.sqler.cfg.DataSources[dbIdx].DsKey()
-			// Compare
-			compare(csvFile, dsKey, schema, baseColumns, baseRowMap, db, query, skipCol, job.batchRow)
-			csvFile.Flush()
-			printer.Info(fmt.Sprintf("[%s] Compared table %s (%d/%d) at db %s (%d/%d) ... ", pkg.Now(),
-				schema, sid+1, len(job.schemas), job.sqler.cfg.DataSources[dbIdx].DsKey(), dbIdx, len(job.sqler.dbs)-1))
-		}
-		csvFile.Flush()
-		if err := file.Close(); err != nil {
-			job.RecordError(err)
-			return
+func (b *bdiffBase) loadFromDb() error {
+	baseDb, dialect := b.sqler.Reader(b.dbIdx), b.sqler.Dialect(b.dbIdx)
+	schema := b.table.name
+	printer.Info(fmt.Sprintf("[%s] Loading %s data: %s", pkg.Now(), b.label, schema))
+	// Skip if too many data
+	pingDb(baseDb)
+	_, result, err := queryAsString(baseDb, b.table.countQuery(dialect))
+	if err != nil {
+		return err
+	}
+	rowNumber, err := strconv.Atoi(result[0][0])
+	if err != nil {
+		return err
+	}
+	if b.maxRow > 0 && b.maxRow < rowNumber {
+		b.skipReason = fmt.Sprintf("too many data in %s (%d > %d)", schema, rowNumber, b.maxRow)
+		return nil
+	}
+
+	// Get base data
+	baseColumns, baseTypes, baseRows, rawRows, err := b.table.queryRawRows(baseDb, dialect, b.table.query(dialect))
+	if err != nil {
+		return err
+	}
+	cols, err := b.table.newBdiffCols(b.sqler.cfg.CommandsConfig, baseColumns, baseTypes)
+	if err != nil {
+		return err
+	}
+	if b.csvFile, err = openBdiffCsv(b.csvFileName, b.state.Resumed(), bdiffCsvHeader(baseColumns)); err != nil {
+		return err
+	}
+	b.columns = baseColumns
+	b.rowMap = rowResultToMap(baseRows, rawRows, cols.keyIdx)
+	b.cols = cols
+	return nil
+}
+
+// release drops the base data and closes the csv file after the last target datasource is compared
+func (b *bdiffBase) release() error {
+	if b.remainJobs.Add(-1) > 0 {
+		return nil
+	}
+	b.rowMap = nil
+	if b.csvFile == nil {
+		return nil
+	}
+	if err := b.csvFile.Close(); err != nil {
+		return err
+	}
+	printer.Info(fmt.Sprintf("[%s] Saved to csv file: %s", pkg.Now(), b.csvFileName))
+	return nil
+}
+
+// syncCsvWriter is a csv writer which can be shared by jobs of different goroutines
+type syncCsvWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *csv.Writer
+}
+
+func newSyncCsvWriter(file *os.File) *syncCsvWriter {
+	return &syncCsvWriter{
+		file: file,
+		w:    csv.NewWriter(file),
+	}
+}
+
+// Write writes records together and flushes them
+func (w *syncCsvWriter) Write(records ...[]string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, record := range records {
+		if err := w.w.Write(record); err != nil {
+			return err
 		}
-		printer.Info(fmt.Sprintf("[%s] Saved to csv file: %s\n", pkg.Now(), csvFileName))
 	}
+	w.w.Flush()
+	return w.w.Error()
+}
 
-	printer.Info(fmt.Sprintf("[%s] All bdiff jobs are jobWg", pkg.Now()))
+func (w *syncCsvWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.w.Flush()
+	return errors.Join(w.w.Error(), w.file.Close())
 }
 
-func compare(csvFile *csv.Writer, dsKey string, schema string, baseColumns []string,
-	baseRowMap map[string]*dataRow, db *sql.DB, query string, skipCol []bool, batchRow int) {
-	offset := 0
+// compare compares the table at db to base in chunks of batchRow, the differences are written to
+// the csv file of base, the finished chunks are recorded in state and skipped if resumed
+func compare(base *bdiffBase, dsKey string, db sqlQueryer, dialect Dialect, batchRow int, entry *bdiffSummaryEntry) error {
+	csvFile, schema, baseColumns, baseRowMap := base.csvFile, base.table.name, base.columns, base.rowMap
+	unit := bdiffUnit{Table: schema, Base: base.name, Target: dsKey}
+	// Order by keys to make chunks stable
+	orderBy := ""
+	keyCols := make([]string, len(base.cols.keyIdx))
+	for i, idx := range base.cols.keyIdx {
+		keyCols[i] = baseColumns[idx]
+	}
 	if batchRow == 0 {
 		batchRow = math.MaxInt
+	} else {
+		orderBy = " order by " + quoteIdents(dialect, keyCols)
 	}
-	for {
-		limitQuery := fmt.Sprintf("%s limit %d offset %d", query, batchRow, offset)
-		// Query target db row data
-		_ = db.Ping()
-		rawRows, err := db.Query(limitQuery)
-		if err != nil {
-			panic(err)
+	compared := make(map[string]bool, len(baseRowMap))
+	for chunk := 0; ; chunk++ {
+		paging := func(query string) string {
+			return dialect.Paging(query+orderBy, batchRow, chunk*batchRow)
 		}
-		columns, rows, err := convertSqlResults(rawRows)
+		pingDb(db)
+		// Only the keys of finished chunk are needed to find missing rows
+		if base.state.Done(unit, strconv.Itoa(chunk)) {
+			_, _, rows, err := base.table.queryRows(db, dialect, paging(fmt.Sprintf("select %s from %s%s",
+				quoteIdents(dialect, keyCols), dialect.QuoteIdent(schema), base.table.where)))
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				compared[strings.Join(row, "\x1f")] = true
+			}
+			entry.Rows += len(rows)
+			continue
+		}
+		// Query target db row data
+		columns, _, rows, rawRows, err := base.table.queryRawRows(db, dialect, paging(base.table.query(dialect)))
 		if err != nil {
-			panic(err)
+			return err
 		}
 		// Skip compare data step if has different columns
 		if !sameCols(baseColumns, columns) {
-			mustWriteToCsv(csvFile, columns, schema, dsKey, "DIFF_TABLE", "")
-			return
+			record := csvRecord(columns, schema, dsKey, "DIFF_TABLE", "")
+			if err := csvFile.Write(record); err != nil {
+				return err
+			}
+			entry.count([][]string{record})
+			return base.state.MarkDone(unit, bdiffChunkEnd)
 		}
 		if len(rows) == 0 {
 			break
 		}
-		rowMap := rowResultToMap(rows)
-		compareRows(csvFile, dsKey, schema, baseColumns, baseRowMap, rowMap, skipCol)
-		offset += batchRow
+		rowMap := rowResultToMap(rows, rawRows, base.cols.keyIdx)
+		records := compareRows(base, dsKey, dialect, rowMap, compared)
+		if err := csvFile.Write(records...); err != nil {
+			return err
+		}
+		entry.Rows += len(rows)
+		entry.count(records)
+		if err := base.state.MarkDone(unit, strconv.Itoa(chunk)); err != nil {
+			return err
+		}
 	}
 	// Find missing rows
-	for _, baseRow := range baseRowMap {
-		if !baseRow.compared {
-			insertSql := generateInsertSql(schema, baseColumns, baseRow.cols)
-			mustWriteToCsv(csvFile, baseRow.cols, schema, dsKey, "MISSING", insertSql)
-			// Reset row flags
-			baseRow.compared = false
+	records := make([][]string, 0)
+	for key, baseRow := range baseRowMap {
+		if !compared[key] {
+			insertSql := generateInsertSql(dialect, schema, baseColumns, baseRow.raw)
+			records = append(records, csvRecord(baseRow.cols, schema, dsKey, "MISSING", insertSql))
 		}
 	}
+	if err := csvFile.Write(records...); err != nil {
+		return err
+	}
+	entry.count(records)
+	return base.state.MarkDone(unit, bdiffChunkEnd)
 }
 
-func compareRows(csvFile *csv.Writer, dsKey string, schema string, baseColumns []string,
-	baseRowMap map[string]*dataRow, rowMap map[string]*dataRow, skipCol []bool) {
-
+// compareRows returns the csv records of different rows and marks the compared base rows
+func compareRows(base *bdiffBase, dsKey string, dialect Dialect, rowMap map[string]*dataRow, compared map[string]bool) [][]string {
+	schema, baseColumns, baseRowMap := base.table.name, base.columns, base.rowMap
+	records := make([][]string, 0)
 	// Find extra rows or different rows
-	for _, row := range rowMap {
-		baseRow, ok := baseRowMap[row.cols[0]]
+	for key, row := range rowMap {
+		baseRow, ok := baseRowMap[key]
 		// Extra row
 		if !ok {
 			// Insert SQL
-			insertSql := generateInsertSql(schema, baseColumns, row.cols)
-			mustWriteToCsv(csvFile, row.cols, schema, dsKey, "EXTRA", insertSql)
+			insertSql := generateInsertSql(dialect, schema, baseColumns, row.raw)
+			records = append(records, csvRecord(row.cols, schema, dsKey, "EXTRA", insertSql))
 			continue
 		}
 		// Different row
-		if same, diff := sameRow(baseRow.cols, row.cols, skipCol); !same {
-			mustWriteToCsv(csvFile, baseRow.cols, schema, "BASE", "DIFF", "")
-			mustWriteToCsv(csvFile, diff, schema, dsKey, "DIFF", "")
+		same, diff, normalized := sameRow(baseRow.cols, row.cols, base.cols)
+		if !same {
+			records = append(records,
+				csvRecord(baseRow.cols, schema, base.label, "DIFF", ""),
+				csvRecord(diff, schema, dsKey, "DIFF", ""))
 		}
-		baseRow.compared = true
+		// Differences suppressed by normalizer rules
+		if normalized != nil {
+			records = append(records, csvRecord(normalized, schema, dsKey, "NORMALIZED", ""))
+		}
+		compared[key] = true
 	}
+	return records
 }
 
-func generateInsertSql(schema string, columns []string, row []string) string {
+func generateInsertSql(dialect Dialect, schema string, columns []string, row []string) string {
 	var sb strings.Builder
 	sb.WriteString("INSERT INTO ")
-	sb.WriteString(schema)
+	sb.WriteString(dialect.QuoteIdent(schema))
 	sb.WriteString("(")
-	sb.WriteString(strings.Join(columns, ","))
+	sb.WriteString(quoteIdents(dialect, columns))
 	sb.WriteString(") VALUES (")
 	for i, col := range row {
 		if col == "NULL" {
 			sb.WriteString("null")
 		} else {
-			sb.WriteString("'" + col + "'")
+			sb.WriteString(dialect.Literal(col))
 		}
 		if i != len(row)-1 {
 			sb.WriteString(",")
@@ -224,50 +686,92 @@ func sameCols(baseCols, cols []string) bool {
 	return true
 }
 
-func sameRow(baseRow, row []string, skipCol []bool) (bool, []string) {
-	diffRow := make([]string, len(baseRow))
-	same := true
+// sameRow compares row to baseRow, the different values are returned in diff and the names of
+// rules which suppressed differences are returned in normalized, other values are "/"
+func sameRow(baseRow, row []string, cols *bdiffCols) (same bool, diff []string, normalized []string) {
 	if len(baseRow) != len(row) {
-		same = false
-		diffRow = row
-		return same, diffRow
+		return false, row, nil
 	}
+	same = true
+	diff = make([]string, len(baseRow))
 	for i := range baseRow {
-		if baseRow[i] != row[i] && !skipCol[i] {
+		diff[i] = "/"
+		if cols.skipCol[i] {
+			continue
+		}
+		sameVal, rule := sameValue(baseRow[i], row[i], cols.rules[i])
+		if !sameVal {
 			same = false
-			diffRow[i] = row[i]
-		} else {
-			diffRow[i] = "/"
+			diff[i] = row[i]
+			continue
+		}
+		if rule != "" {
+			if normalized == nil {
+				normalized = make([]string, len(baseRow))
+				for j := range normalized {
+					normalized[j] = "/"
+				}
+			}
+			normalized[i] = rule
 		}
 	}
-	if !same {
-		// Record id column
-		diffRow[0] = row[0]
+	// Record key columns
+	for _, idx := range cols.keyIdx {
+		if !same {
+			diff[idx] = row[idx]
+		}
+		if normalized != nil {
+			normalized[idx] = row[idx]
+		}
 	}
-	return same, diffRow
-}
-
-func mustWriteToCsv(csvFile *csv.Writer, data []string, schema, dsKey, diffType, sql string) {
-	writeToCsv(csvFile, data, schema, dsKey, diffType, sql)
+	return same, diff, normalized
 }
 
-func writeToCsv(csvFile *csv.Writer, data []string, extraHeaders ...string) {
-	if len(extraHeaders) != 0 {
-		data = append(extraHeaders, data...)
+func mustWriteToCsv(csvFile *syncCsvWriter, records ...[]string) {
+	if len(records) == 0 {
+		return
 	}
-	if err := csvFile.Write(data); err != nil {
+	if err := csvFile.Write(records...); err != nil {
 		panic(err)
 	}
 }
 
-func rowResultToMap(rows [][]string) map[string]*dataRow {
+func bdiffCsvHeader(columns []string) []string {
+	return append([]string{"Table", "DataSource", "Type", "SQL"}, columns...)
+}
+
+// csvRecord prepends the bdiff headers to data
+func csvRecord(data []string, schema, dsKey, diffType, sql string) []string {
+	record := make([]string, 0, len(data)+4)
+	record = append(record, schema, dsKey, diffType, sql)
+	return append(record, data...)
+}
+
+// rowResultToMap keys rows by the key columns, raws are the raw rows of rows and nil if the same
+func rowResultToMap(rows [][]string, raws [][]string, keyIdx []int) map[string]*dataRow {
 	rowMap := make(map[string]*dataRow, len(rows))
-	for _, baseRow := range rows {
-		id := baseRow[0]
+	for i, baseRow := range rows {
+		id := rowKey(baseRow, keyIdx)
+		raw := baseRow
+		if raws != nil {
+			raw = raws[i]
+		}
 		rowMap[id] = &dataRow{
-			cols:     baseRow,
-			compared: false,
+			cols: baseRow,
+			raw:  raw,
 		}
 	}
 	return rowMap
 }
+
+// rowKey joins the key columns of row, a single key column is used as is
+func rowKey(row []string, keyIdx []int) string {
+	if len(keyIdx) == 1 {
+		return row[keyIdx[0]]
+	}
+	keys := make([]string, len(keyIdx))
+	for i, idx := range keyIdx {
+		keys[i] = row[idx]
+	}
+	return strings.Join(keys, "\x1f")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"slices"
+	"sqler/pkg"
+	"strings"
+	"testing"
+)
+
+func TestNewBdiffTable(t *testing.T) {
+	as := assert.New(t)
+	cmdCfg := &pkg.CommandsConfig{
+		BdiffSkipCols: []string{"update_date"},
+		BdiffTables: map[string]*pkg.BdiffTableConfig{
+			"a": {Where: "id > 10", Columns: []string{"id", "name"}, SkipCols: []string{"name"}, Keys: []string{"name", "id"}},
+		},
+	}
+	a := newBdiffTable(cmdCfg, "a")
+	as.Equal("select `id`,`name` from `a` where id > 10", a.query(mysqlDialect{}))
+	as.Equal(`select count(*) from "a" where id > 10`, a.countQuery(sqliteDialect{}))
+	as.True(a.skipColsMap["update_date"])
+	as.True(a.skipColsMap["name"])
+	keyIdx, err := a.keyIndexes([]string{"id", "name"})
+	as.NoError(err)
+	as.Equal([]int{1, 0}, keyIdx)
+
+	b := newBdiffTable(cmdCfg, "b")
+	as.Equal("select * from `b`", b.query(mysqlDialect{}))
+	keyIdx, err = b.keyIndexes([]string{"id", "name"})
+	as.NoError(err)
+	as.Equal([]int{0}, keyIdx)
+	_, err = newBdiffTable(&pkg.CommandsConfig{BdiffTables: map[string]*pkg.BdiffTableConfig{"c": {Keys: []string{"x"}}}}, "c").keyIndexes([]string{"id"})
+	as.Error(err)
+}
+
+func TestBdiffJobParallel(t *testing.T) {
+	as := assert.New(t)
+	s := newFixtureSqler(t)
+	for _, db := range s.dbs {
+		_, err := db.Exec("create table a_copy as select * from a")
+		as.NoError(err)
+	}
+	// The reports of bdiff, csv rows of different targets are written in any order
+	run := func(parallel int) map[string][]string {
+		as.NoError(os.RemoveAll("bdiff"))
+		job := NewBdiffJob(s, []string{"a", "b", "a_copy"}, &BdiffOptions{Parallel: parallel, BatchRow: 1, Mode: BdiffModeBase})
+		job.Exec()
+		as.NoError(job.Error())
+		reports := make(map[string][]string)
+		for _, name := range []string{"a.csv", "b.csv", "a_copy.csv", "summary.json"} {
+			data, err := os.ReadFile(filepath.Join("bdiff", name))
+			as.NoError(err)
+			lines := strings.Split(string(data), "\n")
+			if name != "summary.json" {
+				slices.Sort(lines)
+			}
+			reports[name] = lines
+		}
+		return reports
+	}
+	serial := run(1)
+	as.NotEmpty(serial["a.csv"])
+	as.Equal(serial, run(4))
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"sqler/pkg"
+	"strconv"
+	"strings"
+)
+
+// BdiffMajorityJob compares one table of all datasources, the row shared by most datasources
+// is the reference and only the datasources which differ from it are reported. Every datasource
+// is reported as TIE if no row is shared by most datasources
+type BdiffMajorityJob struct {
+	sqler     *Sqler
+	table     *bdiffTable
+	dbIds     []int
+	maxRow    int
+	tableId   int
+	tableSize int
+	state     *bdiffState
+	summary   *bdiffSummary
+	*BaseJob
+}
+
+func NewBdiffMajorityJob(sqler *Sqler, table *bdiffTable, dbIds []int, maxRow int, tableId int, tableSize int, state *bdiffState,
+	summary *bdiffSummary) Job {
+	return &BdiffMajorityJob{
+		sqler:     sqler,
+		table:     table,
+		dbIds:     dbIds,
+		maxRow:    maxRow,
+		tableId:   tableId,
+		tableSize: tableSize,
+		state:     state,
+		summary:   summary,
+		BaseJob:   NewBaseJob(new(JobCtx)),
+	}
+}
+
+// majorityGroup is the datasources which have the same row
+type majorityGroup struct {
+	row []string
+	// raw are the values of row as read at the first datasource of group
+	raw   []string
+	dbIds []int
+}
+
+func (job *BdiffMajorityJob) BeforeExec() {
+	job.PrintBeforeExec(fmt.Sprintf("[%s] Comparing table %s (%d/%d) at all dbs by majority ... ", pkg.Now(),
+		job.table.name, job.tableId, job.tableSize))
+}
+
+func (job *BdiffMajorityJob) Exec() {
+	schema := job.table.name
+	csvFileName := fmt.Sprintf("bdiff/%s.csv", schema)
+	var csvFile *syncCsvWriter
+	defer func() {
+		if csvFile != nil {
+			job.RecordError(csvFile.Close())
+		}
+		if err := job.Error(); err != nil {
+			for _, dbIdx := range job.dbIds {
+				job.entry(dbIdx).Error = err.Error()
+			}
+			job.RecordError(job.state.MarkFailed(majorityUnit(schema), err))
+		}
+	}()
+
+	// Skip if too many data
+	for _, dbIdx := range job.dbIds {
+		_, result, err := queryAsString(job.sqler.Reader(dbIdx), job.table.countQuery(job.sqler.Dialect(dbIdx)))
+		if job.RecordError(err) {
+			return
+		}
+		rowNumber, err := strconv.Atoi(result[0][0])
+		if job.RecordError(err) {
+			return
+		}
+		if job.maxRow > 0 && job.maxRow < rowNumber {
+			skipReason := fmt.Sprintf("too many data in %s at db %s (%d > %d)", schema, job.dsKey(dbIdx), rowNumber, job.maxRow)
+			for _, dbIdx := range job.dbIds {
+				job.entry(dbIdx).Skipped = skipReason
+			}
+			job.PrintAfterDone(fmt.Sprintf("[%s] Skip comparsion because of %s", pkg.Now(), skipReason))
+			return
+		}
+	}
+
+	// Load rows of all datasources
+	dbColumns := make([][]string, len(job.dbIds))
+	dbTypes := make([][]string, len(job.dbIds))
+	dbRows := make([][][]string, len(job.dbIds))
+	dbRaws := make([][][]string, len(job.dbIds))
+	for i, dbIdx := range job.dbIds {
+		dialect := job.sqler.Dialect(dbIdx)
+		var err error
+		dbColumns[i], dbTypes[i], dbRows[i], dbRaws[i], err = job.table.queryRawRows(job.sqler.Reader(dbIdx), dialect, job.table.query(dialect))
+		if job.RecordError(err) {
+			return
+		}
+		job.entry(dbIdx).Rows = len(dbRows[i])
+	}
+	// The columns shared by most datasources are voted, others skip voting
+	majority := majorityColumns(dbColumns)
+	columns := dbColumns[majority]
+	cols, err := job.table.newBdiffCols(job.sqler.cfg.CommandsConfig, columns, dbTypes[majority])
+	if job.RecordError(err) {
+		return
+	}
+	if csvFile, err = openBdiffCsv(csvFileName, job.state.Resumed(), bdiffCsvHeader(columns)); job.RecordError(err) {
+		return
+	}
+	rowMaps := make([]map[string]*dataRow, len(job.sqler.dbs))
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for i, dbIdx := range job.dbIds {
+		if !sameCols(columns, dbColumns[i]) {
+			record := csvRecord(dbColumns[i], schema, job.dsKey(dbIdx), "DIFF_TABLE", "")
+			if job.RecordError(csvFile.Write(record)) {
+				return
+			}
+			job.entry(dbIdx).count([][]string{record})
+			continue
+		}
+		rowMaps[dbIdx] = rowResultToMap(dbRows[i], dbRaws[i], cols.keyIdx)
+		for _, row := range dbRows[i] {
+			key := rowKey(row, cols.keyIdx)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	voters := 0
+	for _, rowMap := range rowMaps {
+		if rowMap != nil {
+			voters++
+		}
+	}
+	entries := make([]*bdiffSummaryEntry, 0, len(job.dbIds))
+	for _, dbIdx := range job.dbIds {
+		entries = append(entries, job.entry(dbIdx))
+	}
+	for _, key := range keys {
+		records := job.vote(key, columns, cols, rowMaps, voters)
+		if job.RecordError(csvFile.Write(records...)) {
+			return
+		}
+		for _, e := range entries {
+			e.count(records)
+		}
+	}
+	if job.RecordError(job.state.MarkDone(majorityUnit(schema), bdiffChunkEnd)) {
+		return
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Compared table %s (%d/%d) at all dbs by majority, saved to csv file: %s",
+		pkg.Now(), schema, job.tableId, job.tableSize, csvFileName))
+}
+
+// vote finds the consensus row of key and returns the csv records of datasources which differ from it
+func (job *BdiffMajorityJob) vote(key string, columns []string, cols *bdiffCols,
+	rowMaps []map[string]*dataRow, voters int) [][]string {
+	schema := job.table.name
+	records := make([][]string, 0)
+	groups := make([]*majorityGroup, 0, 2)
+	absent := make([]int, 0)
+	present := 0
+	for dbIdx, rowMap := range rowMaps {
+		if rowMap == nil {
+			continue
+		}
+		row, ok := rowMap[key]
+		if !ok {
+			absent = append(absent, dbIdx)
+			continue
+		}
+		present++
+		var group *majorityGroup
+		for _, g := range groups {
+			if same, _, normalized := sameRow(g.row, row.cols, cols); same {
+				group = g
+				// Differences suppressed by normalizer rules
+				if normalized != nil {
+					records = append(records, csvRecord(normalized, schema, job.dsKey(dbIdx), "NORMALIZED", ""))
+				}
+				break
+			}
+		}
+		if group == nil {
+			group = &majorityGroup{row: row.cols, raw: row.raw}
+			groups = append(groups, group)
+		}
+		group.dbIds = append(group.dbIds, dbIdx)
+	}
+
+	// Most datasources do not have the row
+	if present*2 < voters {
+		for _, g := range groups {
+			for _, dbIdx := range g.dbIds {
+				insertSql := generateInsertSql(job.sqler.Dialect(dbIdx), schema, columns, g.raw)
+				records = append(records, csvRecord(g.row, schema, job.dsKey(dbIdx), "EXTRA", insertSql))
+			}
+		}
+		return records
+	}
+
+	// No consensus if as many datasources have the row as not, or the largest groups are equal
+	tied := present*2 == voters
+	consensus := groups[0]
+	for _, g := range groups[1:] {
+		switch {
+		case len(g.dbIds) > len(consensus.dbIds):
+			consensus = g
+			tied = present*2 == voters
+		case len(g.dbIds) == len(consensus.dbIds):
+			tied = true
+		}
+	}
+	if tied {
+		return append(records, job.tieRecords(key, cols, groups, absent, len(columns))...)
+	}
+	for _, g := range groups {
+		if g == consensus {
+			continue
+		}
+		_, diff, _ := sameRow(consensus.row, g.row, cols)
+		for _, dbIdx := range g.dbIds {
+			records = append(records,
+				csvRecord(consensus.row, schema, "CONSENSUS", "DIFF", ""),
+				csvRecord(diff, schema, job.dsKey(dbIdx), "DIFF", ""))
+		}
+	}
+	for _, dbIdx := range absent {
+		insertSql := generateInsertSql(job.sqler.Dialect(dbIdx), schema, columns, consensus.raw)
+		records = append(records, csvRecord(consensus.row, schema, job.dsKey(dbIdx), "MISSING", insertSql))
+	}
+	return records
+}
+
+// tieRecords flags every datasource of key with its row, only the key columns are known if absent
+func (job *BdiffMajorityJob) tieRecords(key string, cols *bdiffCols, groups []*majorityGroup, absent []int, columnSize int) [][]string {
+	records := make([][]string, 0, len(groups)+len(absent))
+	for _, g := range groups {
+		for _, dbIdx := range g.dbIds {
+			records = append(records, csvRecord(g.row, job.table.name, job.dsKey(dbIdx), "TIE", ""))
+		}
+	}
+	keyRow := make([]string, columnSize)
+	for i, keyValue := range strings.SplitN(key, "\x1f", len(cols.keyIdx)) {
+		keyRow[cols.keyIdx[i]] = keyValue
+	}
+	for _, dbIdx := range absent {
+		records = append(records, csvRecord(keyRow, job.table.name, job.dsKey(dbIdx), "TIE", ""))
+	}
+	return records
+}
+
+// majorityColumns returns the index of columns shared by most datasources, the first of them if tied
+func majorityColumns(dbColumns [][]string) int {
+	majority, majorityVotes := 0, 0
+	for i := range dbColumns {
+		votes := 0
+		for j := range dbColumns {
+			if sameCols(dbColumns[i], dbColumns[j]) {
+				votes++
+			}
+		}
+		if votes > majorityVotes {
+			majority, majorityVotes = i, votes
+		}
+	}
+	return majority
+}
+
+// majorityUnit is the unit of table in bdiff state, all datasources are compared at once
+func majorityUnit(table string) bdiffUnit {
+	return bdiffUnit{Table: table, Base: BdiffModeMajority, Target: "*"}
+}
+
+// entry is the summary entry of datasource, the consensus is its base
+func (job *BdiffMajorityJob) entry(dbIdx int) *bdiffSummaryEntry {
+	return job.summary.Entry(job.table.name, BdiffModeMajority, job.dsKey(dbIdx), fmt.Sprintf("bdiff/%s.csv", job.table.name))
+}
+
+func (job *BdiffMajorityJob) dsKey(dbIdx int) string {
+	return job.sqler.cfg.DataSources[dbIdx].DsKey()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sqler/pkg"
+	"strings"
+	"testing"
+)
+
+func TestBdiffMajorityVote(t *testing.T) {
+	as := assert.New(t)
+	cfg := pkg.NewConfig()
+	for _, schema := range []string{"s0", "s1", "s2"} {
+		cfg.AddDataSource(&pkg.DataSourceConfig{Type: "sqlite3", Schema: schema})
+	}
+	s := &Sqler{ctx: context.Background(), cfg: cfg}
+	table := newBdiffTable(cfg.CommandsConfig, "t")
+	columns := []string{"id", "v"}
+	cols, err := table.newBdiffCols(cfg.CommandsConfig, columns, []string{"INT", "TEXT"})
+	as.NoError(err)
+	job := &BdiffMajorityJob{sqler: s, table: table}
+
+	cases := []struct {
+		name string
+		rows []string
+		// want are the datasource and type of records
+		want []string
+	}{
+		{"2 vs 1", []string{"a", "a", "b"}, []string{"CONSENSUS DIFF", "/s2 DIFF"}},
+		{"all same", []string{"a", "a", "a"}, []string{}},
+		{"1-1-1", []string{"a", "b", "c"}, []string{"/s0 TIE", "/s1 TIE", "/s2 TIE"}},
+		{"missing on minority", []string{"a", "", "a"}, []string{"/s1 MISSING"}},
+		{"missing on majority", []string{"", "a", ""}, []string{"/s1 EXTRA"}},
+		{"1-1 and missing", []string{"a", "b", ""}, []string{"/s0 TIE", "/s1 TIE", "/s2 TIE"}},
+	}
+	// rowMaps has row 1 of value at each datasource, absent if empty
+	rowMaps := func(values []string) []map[string]*dataRow {
+		maps := make([]map[string]*dataRow, len(values))
+		for dbIdx, v := range values {
+			maps[dbIdx] = make(map[string]*dataRow)
+			if v != "" {
+				maps[dbIdx]["1"] = &dataRow{cols: []string{"1", v}, raw: []string{"1", "raw " + v}}
+			}
+		}
+		return maps
+	}
+	for _, c := range cases {
+		records := job.vote("1", columns, cols, rowMaps(c.rows), len(c.rows))
+		got := make([]string, 0, len(records))
+		for _, record := range records {
+			got = append(got, record[1]+" "+record[2])
+		}
+		as.Equal(c.want, got, c.name)
+	}
+
+	// Only the key of absent row is known
+	records := job.vote("1", columns, cols, rowMaps([]string{"a", "b", ""}), 3)
+	as.Equal([]string{"t", "/s2", "TIE", "", "1", ""}, records[2])
+	// As many datasources have the row as not
+	records = job.vote("1", columns, cols, rowMaps([]string{"a", ""}), 2)
+	as.Equal([]string{"/s0", "/s1"}, []string{records[0][1], records[1][1]})
+	as.Equal("TIE", records[1][2])
+	// The repair SQL is built from the raw values
+	records = job.vote("1", columns, cols, rowMaps([]string{"a", "", "a"}), 3)
+	as.Equal(`INSERT INTO "t"("id","v") VALUES ('1','raw a')`, records[0][3])
+}
+
+func TestBdiffMajorityColumns(t *testing.T) {
+	as := assert.New(t)
+	as.Equal(1, majorityColumns([][]string{{"id", "x"}, {"id"}, {"id"}}))
+	as.Equal(0, majorityColumns([][]string{{"id", "x"}, {"id"}}))
+
+	// Only the datasource of other columns is flagged, the others are voted
+	s := newFixtureSqler(t)
+	_, err := s.dbs[0].Exec("alter table a add column a4 text")
+	as.NoError(err)
+	job := NewBdiffJob(s, []string{"a"}, &BdiffOptions{Parallel: 1, Mode: BdiffModeMajority})
+	job.Exec()
+	as.NoError(job.Error())
+	data, err := os.ReadFile("bdiff/a.csv")
+	as.NoError(err)
+	report := string(data)
+	as.True(strings.HasPrefix(report, "Table,DataSource,Type,SQL,id,a1,a2,a3\n"), report)
+	as.Contains(report, "a,/db_base,DIFF_TABLE,,id,a1,a2,a3,a4\n")
+	as.NotContains(report, "/db_01,DIFF_TABLE")
+	as.NotContains(report, "/db_02,DIFF_TABLE")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sqler/pkg"
+	"strings"
+	"time"
+)
+
+// bdiffRule normalizes values before they are compared
+type bdiffRule interface {
+	// Name is recorded in the report when the rule suppressed a difference
+	Name() string
+	// Normalize returns the canonical form of v, v is returned as is if it can not be normalized
+	Normalize(v string) string
+	// Equal reports whether two normalized values are the same
+	Equal(a, b string) bool
+}
+
+// parseBdiffRule parses rules like "trim", "lower", "numeric:0.01", "datetime:s", "tz:+08:00" and "json"
+func parseBdiffRule(rule string) (bdiffRule, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(rule), ":")
+	switch strings.ToLower(name) {
+	case "trim":
+		return &funcRule{name: rule, f: strings.TrimSpace}, nil
+	case "lower":
+		return &funcRule{name: rule, f: strings.ToLower}, nil
+	case "numeric":
+		tolerance := new(big.Rat)
+		if arg != "" {
+			if _, ok := tolerance.SetString(arg); !ok {
+				return nil, fmt.Errorf("invalid numeric tolerance %s", arg)
+			}
+		}
+		return &numericRule{name: rule, tolerance: tolerance}, nil
+	case "datetime":
+		unit, ok := datetimeUnits[arg]
+		if arg == "" {
+			unit, ok = time.Second, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid datetime unit %s, must be one of d, h, m, s, ms, us", arg)
+		}
+		return &datetimeRule{name: rule, unit: unit}, nil
+	case "tz":
+		loc, err := parseLocation(arg)
+		if err != nil {
+			return nil, err
+		}
+		return &datetimeRule{name: rule, loc: loc}, nil
+	case "json":
+		return &funcRule{name: rule, f: canonicalJson}, nil
+	}
+	return nil, fmt.Errorf("unknown bdiff normalizer rule %s", rule)
+}
+
+// parseBdiffRules returns the rules of each column, nil if no rule matches the column
+func parseBdiffRules(normalizers []*pkg.BdiffNormalizerConfig, table string, columns []string, types []string) ([][]bdiffRule, error) {
+	colRules := make([][]bdiffRule, len(columns))
+	for _, n := range normalizers {
+		if len(n.Tables) > 0 && !containsFold(n.Tables, table) {
+			continue
+		}
+		for i, column := range columns {
+			colType := ""
+			if i < len(types) {
+				colType = baseTypeName(types[i])
+			}
+			if !containsFold(n.Columns, column) && !containsFold(n.Types, colType) {
+				continue
+			}
+			for _, r := range n.Rules {
+				rule, err := parseBdiffRule(r)
+				if err != nil {
+					return nil, err
+				}
+				colRules[i] = append(colRules[i], rule)
+			}
+		}
+	}
+	return colRules, nil
+}
+
+// sameValue compares base and target value by rules, the name of rule is returned if
+// it made two different values the same
+func sameValue(base, target string, rules []bdiffRule) (bool, string) {
+	if base == target {
+		return true, ""
+	}
+	if base == "NULL" || target == "NULL" {
+		return false, ""
+	}
+	for _, rule := range rules {
+		base, target = rule.Normalize(base), rule.Normalize(target)
+		if rule.Equal(base, target) {
+			return true, rule.Name()
+		}
+	}
+	return false, ""
+}
+
+type funcRule struct {
+	name string
+	f    func(string) string
+}
+
+func (r *funcRule) Name() string {
+	return r.name
+}
+
+func (r *funcRule) Normalize(v string) string {
+	return r.f(v)
+}
+
+func (r *funcRule) Equal(a, b string) bool {
+	return a == b
+}
+
+type numericRule struct {
+	name      string
+	tolerance *big.Rat
+}
+
+func (r *numericRule) Name() string {
+	return r.name
+}
+
+// Normalize removes the redundant zeros, "1.50" and "01.5" are normalized to "1.5"
+func (r *numericRule) Normalize(v string) string {
+	return canonicalNumber(strings.TrimSpace(v))
+}
+
+func (r *numericRule) Equal(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, okA := new(big.Rat).SetString(a)
+	rb, okB := new(big.Rat).SetString(b)
+	if !okA || !okB {
+		return false
+	}
+	delta := ra.Sub(ra, rb)
+	return delta.Abs(delta).Cmp(r.tolerance) <= 0
+}
+
+var datetimeUnits = map[string]time.Duration{
+	"d":  24 * time.Hour,
+	"h":  time.Hour,
+	"m":  time.Minute,
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+}
+
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// datetimeRule truncates datetime to unit or converts datetime with zone to loc
+type datetimeRule struct {
+	name string
+	unit time.Duration
+	loc  *time.Location
+}
+
+func (r *datetimeRule) Name() string {
+	return r.name
+}
+
+func (r *datetimeRule) Normalize(v string) string {
+	t, hasZone, ok := parseDatetime(v)
+	if !ok {
+		return v
+	}
+	if r.loc != nil && hasZone {
+		t = t.In(r.loc)
+	}
+	if r.unit > 0 {
+		// Truncate on the wall clock, time.Truncate works on the absolute time
+		_, offset := t.Zone()
+		shift := time.Duration(offset) * time.Second
+		t = t.Add(shift).Truncate(r.unit).Add(-shift)
+	}
+	return t.Format("2006-01-02 15:04:05.999999999")
+}
+
+func (r *datetimeRule) Equal(a, b string) bool {
+	return a == b
+}
+
+func parseDatetime(v string) (time.Time, bool, bool) {
+	v = strings.TrimSpace(v)
+	for i, layout := range datetimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, i < 3, true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// parseLocation parses offsets like "+08:00" or location names like "Asia/Shanghai"
+func parseLocation(tz string) (*time.Location, error) {
+	if t, err := time.Parse("-07:00", tz); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %s: %w", tz, err)
+	}
+	return loc, nil
+}
+
+// canonicalJson sorts the keys of json objects and removes the spaces
+func canonicalJson(v string) string {
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.UseNumber()
+	var data any
+	if err := decoder.Decode(&data); err != nil {
+		return v
+	}
+	b := new(bytes.Buffer)
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return v
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// baseTypeName returns the type name without length, e.g. "decimal(10,2)" to "DECIMAL"
+func baseTypeName(colType string) string {
+	name, _, _ := strings.Cut(colType, "(")
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+func containsFold(values []string, v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
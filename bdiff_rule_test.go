@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sqler/pkg"
+	"testing"
+)
+
+func TestBdiffRules(t *testing.T) {
+	as := assert.New(t)
+	mustRule := func(r string) bdiffRule {
+		rule, err := parseBdiffRule(r)
+		as.NoError(err)
+		return rule
+	}
+	cases := []struct {
+		rule   string
+		base   string
+		target string
+		same   bool
+	}{
+		{"trim", "a ", "a", true},
+		{"lower", "ABC", "abc", true},
+		{"numeric", "1.50", "1.5", true},
+		{"numeric", "10", "10.000", true},
+		{"numeric", "1.5", "1.51", false},
+		{"numeric:0.01", "1.5", "1.51", true},
+		{"numeric", "1e-10", "2e-10", false},
+		{"numeric", "1e-10", "0.0000000001", true},
+		{"numeric", "1.5E+3", "1500", true},
+		{"datetime", "2024-01-02 10:00:00.123", "2024-01-02 10:00:00", true},
+		{"datetime:m", "2024-01-02 10:00:59", "2024-01-02 10:00:00", true},
+		{"datetime:ms", "2024-01-02 10:00:00.123", "2024-01-02 10:00:00", false},
+		{"tz:+08:00", "2024-01-02T02:00:00Z", "2024-01-02 10:00:00+08:00", true},
+		{"json", `{"b": 1, "a": [1, 2]}`, `{"a":[1,2],"b":1}`, true},
+		{"json", `{"a": 1}`, `{"a": 2}`, false},
+	}
+	for _, c := range cases {
+		same, rule := sameValue(c.base, c.target, []bdiffRule{mustRule(c.rule)})
+		as.Equal(c.same, same, c.rule+": "+c.base+" <> "+c.target)
+		if same {
+			as.Equal(c.rule, rule)
+		}
+	}
+	same, _ := sameValue("NULL", "0", []bdiffRule{mustRule("numeric:1")})
+	as.False(same)
+	_, err := parseBdiffRule("unknown")
+	as.Error(err)
+}
+
+func TestParseBdiffRules(t *testing.T) {
+	as := assert.New(t)
+	normalizers := []*pkg.BdiffNormalizerConfig{
+		{Tables: []string{"a"}, Columns: []string{"price"}, Rules: []string{"numeric"}},
+		{Types: []string{"varchar"}, Rules: []string{"trim", "lower"}},
+	}
+	rules, err := parseBdiffRules(normalizers, "a", []string{"id", "price", "name"}, []string{"INT", "DECIMAL", "VARCHAR"})
+	as.NoError(err)
+	as.Len(rules[0], 0)
+	as.Len(rules[1], 1)
+	as.Len(rules[2], 2)
+	rules, err = parseBdiffRules(normalizers, "b", []string{"id", "price"}, []string{"INT", "DECIMAL"})
+	as.NoError(err)
+	as.Len(rules[1], 0)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sqler/pkg"
+	"strings"
+)
+
+const (
+	// BdiffSnapshotSave records the row hashes of datasources to the snapshot file
+	BdiffSnapshotSave = "save"
+	// BdiffSnapshotCompare compares datasources to the row hashes in the snapshot file
+	BdiffSnapshotCompare = "compare"
+)
+
+const stmtCreateSnapshotTables = `
+create table if not exists snapshot_tables (ds text, tbl text, columns text, created_at text, primary key (ds, tbl));
+create table if not exists snapshot_rows (ds text, tbl text, row_key text, row_hash text, primary key (ds, tbl, row_key));`
+
+// BdiffSnapshotJob saves the row hashes of tables to a SQLite file, or compares tables
+// to the saved row hashes to find the rows added, removed or changed since then
+type BdiffSnapshotJob struct {
+	sqler    *Sqler
+	mode     string
+	fileName string
+	schemas  []string
+	opts     *BdiffOptions
+	*BaseJob
+}
+
+func NewBdiffSnapshotJob(sqler *Sqler, mode string, fileName string, schemas []string, opts *BdiffOptions) Job {
+	if len(schemas) == 0 {
+		schemas = sqler.cfg.CommandsConfig.BdiffSchemas
+	}
+	return &BdiffSnapshotJob{
+		sqler:    sqler,
+		mode:     mode,
+		fileName: fileName,
+		schemas:  schemas,
+		opts:     opts,
+		BaseJob:  NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *BdiffSnapshotJob) Exec() {
+	if job.mode != BdiffSnapshotSave && job.mode != BdiffSnapshotCompare {
+		job.RecordError(fmt.Errorf("unknown bdiff snapshot mode %s, must be %s or %s", job.mode, BdiffSnapshotSave, BdiffSnapshotCompare))
+		return
+	}
+	if job.fileName == "" {
+		job.RecordError(errors.New("snapshot file is required"))
+		return
+	}
+	if job.mode == BdiffSnapshotCompare {
+		if _, err := os.Stat(job.fileName); job.RecordError(err) {
+			return
+		}
+		if err := os.Mkdir("bdiff", 0755); err != nil && !os.IsExist(err) {
+			job.RecordError(err)
+			return
+		}
+	}
+	snapshotDb, err := sql.Open("sqlite3", "file:"+job.fileName)
+	if job.RecordError(err) {
+		return
+	}
+	defer snapshotDb.Close()
+	snapshotDb.SetMaxOpenConns(1)
+	if _, err := snapshotDb.Exec(stmtCreateSnapshotTables); job.RecordError(err) {
+		return
+	}
+
+	// All datasources or the one specified by opts.Base
+	dbIds := make([]int, 0, len(job.sqler.dbs))
+	if job.opts.Base != "" {
+		dbIdx, err := job.sqler.cfg.DataSourceIndex(job.opts.Base)
+		if job.RecordError(err) {
+			return
+		}
+		dbIds = append(dbIds, dbIdx)
+	} else {
+		for dbIdx := range job.sqler.dbs {
+			dbIds = append(dbIds, dbIdx)
+		}
+	}
+
+	csvFiles := make(map[string]*syncCsvWriter)
+	defer func() {
+		for _, csvFile := range csvFiles {
+			job.RecordError(csvFile.Close())
+		}
+	}()
+	for sid, schema := range job.schemas {
+		table := newBdiffTable(job.sqler.cfg.CommandsConfig, schema)
+		for _, dbIdx := range dbIds {
+			dsKey := job.sqler.cfg.DataSources[dbIdx].DsKey()
+			printer.Info(fmt.Sprintf("[%s] Snapshot %s table %s (%d/%d) at db %s", pkg.Now(), job.mode,
+				schema, sid+1, len(job.schemas), dsKey))
+			if job.mode == BdiffSnapshotSave {
+				err = job.save(snapshotDb, table, dbIdx)
+			} else {
+				err = job.compare(snapshotDb, table, dbIdx, csvFiles)
+			}
+			if err != nil {
+				job.RecordError(fmt.Errorf("failed to %s snapshot of table %s at db %s: %w", job.mode, schema, dsKey, err))
+			}
+		}
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Snapshot %s is done: %s", pkg.Now(), job.mode, job.fileName))
+}
+
+// save replaces the row hashes of table at datasource dbIdx in snapshot
+func (job *BdiffSnapshotJob) save(snapshotDb *sql.DB, table *bdiffTable, dbIdx int) error {
+	dsKey := job.sqler.cfg.DataSources[dbIdx].DsKey()
+	tx, err := snapshotDb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("delete from snapshot_rows where ds = ? and tbl = ?", dsKey, table.name); err != nil {
+		return err
+	}
+	insert, err := tx.Prepare("insert into snapshot_rows (ds, tbl, row_key, row_hash) values (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	columns, _, err := scanRowHashes(job.sqler.Reader(dbIdx), job.sqler.Dialect(dbIdx), job.sqler.cfg.CommandsConfig, table, job.opts.BatchRow, nil,
+		func(key string, hash string, _ []string) error {
+			_, err := insert.Exec(dsKey, table.name, key, hash)
+			return err
+		})
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("insert or replace into snapshot_tables (ds, tbl, columns, created_at) values (?, ?, ?, ?)",
+		dsKey, table.name, strings.Join(columns, ","), pkg.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// compare writes the rows added (EXTRA), removed (MISSING) and changed (DIFF) since the snapshot
+func (job *BdiffSnapshotJob) compare(snapshotDb *sql.DB, table *bdiffTable, dbIdx int, csvFiles map[string]*syncCsvWriter) error {
+	dsKey := job.sqler.cfg.DataSources[dbIdx].DsKey()
+	var snapshotColumns, createdAt string
+	err := snapshotDb.QueryRow("select columns, created_at from snapshot_tables where ds = ? and tbl = ?", dsKey, table.name).
+		Scan(&snapshotColumns, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		printer.Info(fmt.Sprintf("[%s] Skip comparsion because table %s at db %s is not in snapshot", pkg.Now(), table.name, dsKey))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	hashes := make(map[string]string)
+	_, rows, err := queryAsString(snapshotDb, "select row_key, row_hash from snapshot_rows where ds = ? and tbl = ?", dsKey, table.name)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		hashes[row[0]] = row[1]
+	}
+
+	csvFile := csvFiles[table.name]
+	errDiffTable := errors.New("different columns")
+	compared := make(map[string]bool, len(hashes))
+	columns, cols, err := scanRowHashes(job.sqler.Reader(dbIdx), job.sqler.Dialect(dbIdx), job.sqler.cfg.CommandsConfig, table, job.opts.BatchRow,
+		func(columns []string) error {
+			if csvFile == nil {
+				file, err := os.OpenFile(fmt.Sprintf("bdiff/%s.csv", table.name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
+				if err != nil {
+					return err
+				}
+				csvFile = newSyncCsvWriter(file)
+				csvFiles[table.name] = csvFile
+				if err := csvFile.Write(append([]string{"Table", "DataSource", "Type", "SQL"}, columns...)); err != nil {
+					return err
+				}
+			}
+			// Skip compare data step if has different columns
+			if strings.Join(columns, ",") != snapshotColumns {
+				mustWriteToCsv(csvFile, csvRecord(strings.Split(snapshotColumns, ","), table.name, dsKey, "DIFF_TABLE", ""))
+				return errDiffTable
+			}
+			return nil
+		},
+		func(key string, hash string, row []string) error {
+			snapshotHash, ok := hashes[key]
+			switch {
+			case !ok:
+				mustWriteToCsv(csvFile, csvRecord(row, table.name, dsKey, "EXTRA", ""))
+			case snapshotHash != hash:
+				mustWriteToCsv(csvFile, csvRecord(row, table.name, dsKey, "DIFF", ""))
+			}
+			compared[key] = true
+			return nil
+		})
+	if errors.Is(err, errDiffTable) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Removed rows, only key columns are known
+	records := make([][]string, 0)
+	for key := range hashes {
+		if compared[key] {
+			continue
+		}
+		row := make([]string, len(columns))
+		for i, keyValue := range strings.SplitN(key, "\x1f", len(cols.keyIdx)) {
+			row[cols.keyIdx[i]] = keyValue
+		}
+		records = append(records, csvRecord(row, table.name, dsKey, "MISSING", ""))
+	}
+	mustWriteToCsv(csvFile, records...)
+	printer.Info(fmt.Sprintf("[%s] Compared table %s at db %s to snapshot created at %s", pkg.Now(), table.name, dsKey, createdAt))
+	return nil
+}
+
+// scanRowHashes queries table in batches, onColumns is called with the columns before the
+// first row and handle is called with the key and hash of every row
+func scanRowHashes(db sqlQueryer, dialect Dialect, cmdCfg *pkg.CommandsConfig, table *bdiffTable, batchRow int,
+	onColumns func(columns []string) error, handle func(key string, hash string, row []string) error) ([]string, *bdiffCols, error) {
+	var columns []string
+	var cols *bdiffCols
+	// Order by keys to make batches stable
+	orderBy := ""
+	if batchRow > 0 {
+		var err error
+		if orderBy, err = table.orderBy(db, dialect); err != nil {
+			return nil, nil, err
+		}
+	}
+	err := queryInChunks(db, dialect, table.query(dialect), orderBy, batchRow, func(batchColumns []string, types []string, rows [][]string) error {
+		if cols == nil {
+			columns = batchColumns
+			var err error
+			if cols, err = table.newBdiffCols(cmdCfg, columns, types); err != nil {
+				return err
+			}
+			if onColumns != nil {
+				if err := onColumns(columns); err != nil {
+					return err
+				}
+			}
+		}
+		for _, row := range rows {
+			if err := handle(rowKey(row, cols.keyIdx), rowHash(row, cols), row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns, cols, nil
+}
+
+// rowHash hashes the values of row which are not skipped, the values are normalized by rules
+func rowHash(row []string, cols *bdiffCols) string {
+	h := sha256.New()
+	for i, v := range row {
+		if cols.skipCol[i] {
+			continue
+		}
+		if v != "NULL" {
+			for _, rule := range cols.rules[i] {
+				v = rule.Normalize(v)
+			}
+		}
+		h.Write([]byte(v))
+		h.Write([]byte{0x1f})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestBdiffSnapshotSaveCompare(t *testing.T) {
+	as := assert.New(t)
+	s := newFixtureSqler(t)
+	opts := &BdiffOptions{Base: "base", BatchRow: 2}
+	job := NewBdiffSnapshotJob(s, BdiffSnapshotSave, "snapshot.sqlite", []string{"a"}, opts)
+	job.Exec()
+	as.NoError(job.Error())
+
+	// Rows inserted before the saved ones move them across batches
+	_, err := s.dbs[0].Exec(`insert into a values (0, 'a1_0', 'a2_0', 0), (4, 'a1_4', 'a2_4', 4);
+delete from a where id = 3;
+update a set a2 = 'changed' where id = 5;`)
+	as.NoError(err)
+	job = NewBdiffSnapshotJob(s, BdiffSnapshotCompare, "snapshot.sqlite", []string{"a"}, opts)
+	job.Exec()
+	as.NoError(job.Error())
+
+	file, err := os.Open("bdiff/a.csv")
+	as.NoError(err)
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	as.NoError(err)
+	types := make(map[string]string)
+	for _, record := range records[1:] {
+		types[record[4]] = record[2]
+	}
+	as.Equal(map[string]string{"0": "EXTRA", "4": "EXTRA", "3": "MISSING", "5": "DIFF"}, types)
+}
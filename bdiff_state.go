@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	bdiffStateFileName = "bdiff/state.log"
+	// bdiffChunkEnd marks the unit as finished after all chunks and missing rows are compared
+	bdiffChunkEnd = "end"
+)
+
+// bdiffUnit is a table of target datasource compared to a base
+type bdiffUnit struct {
+	Table  string
+	Base   string
+	Target string
+}
+
+func (u bdiffUnit) String() string {
+	return fmt.Sprintf("%s (%s -> %s)", u.Table, u.Base, u.Target)
+}
+
+// bdiffState records the finished chunks of units to an append only file,
+// so that an interrupted bdiff can be resumed
+type bdiffState struct {
+	mu     sync.Mutex
+	file   *os.File
+	resume bool
+	done   map[string]bool
+	// settings are the settings of the recorded chunks keyed by names
+	settings map[string]string
+}
+
+// openBdiffState loads the finished chunks if resume, otherwise the state file is truncated.
+// The chunks are the rows of settings, e.g. the batch row, so the state can not be resumed if a
+// setting recorded by last bdiff is changed. settings are "name\tvalue", the new ones are recorded
+func openBdiffState(fileName string, resume bool, settings []string) (*bdiffState, error) {
+	s := &bdiffState{
+		resume:   resume,
+		done:     make(map[string]bool),
+		settings: make(map[string]string),
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		if err := s.load(fileName); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	newSettings := make([]string, 0, len(settings))
+	for _, setting := range settings {
+		name, value, _ := strings.Cut(setting, "\t")
+		last, ok := s.settings[name]
+		if !ok {
+			newSettings = append(newSettings, setting)
+			continue
+		}
+		if last != value {
+			return nil, fmt.Errorf("can not resume bdiff as %s is changed from %s to %s", name, last, value)
+		}
+	}
+	file, err := os.OpenFile(fileName, flag, 0665)
+	if err != nil {
+		return nil, err
+	}
+	for _, setting := range newSettings {
+		if _, err := file.WriteString("setting\t" + setting + "\n"); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	s.file = file
+	return s, nil
+}
+
+// load reads lines like "done\ttable\tbase\ttarget\tchunk" and "setting\tname\tvalue"
+func (s *bdiffState) load(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) == 5 && fields[0] == "done" {
+			s.done[strings.Join(fields[1:], "\t")] = true
+		}
+		if len(fields) == 3 && fields[0] == "setting" {
+			s.settings[fields[1]] = fields[2]
+		}
+	}
+	return scanner.Err()
+}
+
+func stateKey(unit bdiffUnit, chunk string) string {
+	return strings.Join([]string{unit.Table, unit.Base, unit.Target, chunk}, "\t")
+}
+
+// Done reports whether chunk of unit is finished, use bdiffChunkEnd for the whole unit
+func (s *bdiffState) Done(unit bdiffUnit, chunk string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done[stateKey(unit, chunk)]
+}
+
+func (s *bdiffState) MarkDone(unit bdiffUnit, chunk string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done[stateKey(unit, chunk)] = true
+	_, err := s.file.WriteString("done\t" + stateKey(unit, chunk) + "\n")
+	return err
+}
+
+// MarkFailed records the error of unit, failed units are compared again when resumed
+func (s *bdiffState) MarkFailed(unit bdiffUnit, cause error) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := strings.NewReplacer("\t", " ", "\n", " ").Replace(cause.Error())
+	_, err := s.file.WriteString(fmt.Sprintf("failed\t%s\t%s\t%s\t%s\n", unit.Table, unit.Base, unit.Target, msg))
+	return err
+}
+
+// Resumed reports whether the finished chunks of last bdiff are skipped
+func (s *bdiffState) Resumed() bool {
+	return s != nil && s.resume
+}
+
+func (s *bdiffState) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// openBdiffCsv opens the csv report, it is appended if resume, the header is written if the file is empty
+func openBdiffCsv(fileName string, resume bool, header []string) (*syncCsvWriter, error) {
+	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flag = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(fileName, flag, 0665)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	csvFile := newSyncCsvWriter(file)
+	if stat.Size() == 0 && header != nil {
+		if err := csvFile.Write(header); err != nil {
+			_ = csvFile.Close()
+			return nil, err
+		}
+	}
+	return csvFile, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestBdiffState(t *testing.T) {
+	as := assert.New(t)
+	fileName := filepath.Join(t.TempDir(), "state.log")
+	unit := bdiffUnit{Table: "a", Base: "/db_base", Target: "/db_01"}
+
+	state, err := openBdiffState(fileName, false, nil)
+	as.NoError(err)
+	as.NoError(state.MarkDone(unit, "0"))
+	as.NoError(state.MarkFailed(unit, errors.New("bad\tconnection")))
+	as.NoError(state.Close())
+
+	state, err = openBdiffState(fileName, true, nil)
+	as.NoError(err)
+	as.True(state.Done(unit, "0"))
+	as.False(state.Done(unit, "1"))
+	as.False(state.Done(unit, bdiffChunkEnd))
+	as.NoError(state.Close())
+
+	state, err = openBdiffState(fileName, false, nil)
+	as.NoError(err)
+	as.False(state.Done(unit, "0"))
+	as.NoError(state.Close())
+}
+
+func TestBdiffStateSettings(t *testing.T) {
+	as := assert.New(t)
+	fileName := filepath.Join(t.TempDir(), "state.log")
+	state, err := openBdiffState(fileName, false, []string{"batch row\t2", "where/columns/keys of a\t01"})
+	as.NoError(err)
+	as.NoError(state.Close())
+
+	// Settings of other tables are recorded too
+	state, err = openBdiffState(fileName, true, []string{"batch row\t2", "where/columns/keys of b\t02"})
+	as.NoError(err)
+	as.NoError(state.Close())
+
+	_, err = openBdiffState(fileName, true, []string{"batch row\t3"})
+	as.EqualError(err, "can not resume bdiff as batch row is changed from 2 to 3")
+	_, err = openBdiffState(fileName, true, []string{"where/columns/keys of b\t03"})
+	as.EqualError(err, "can not resume bdiff as where/columns/keys of b is changed from 02 to 03")
+
+	// A new bdiff records its settings again
+	state, err = openBdiffState(fileName, false, []string{"batch row\t3"})
+	as.NoError(err)
+	as.NoError(state.Close())
+	state, err = openBdiffState(fileName, true, []string{"batch row\t3", "where/columns/keys of b\t03"})
+	as.NoError(err)
+	as.NoError(state.Close())
+}
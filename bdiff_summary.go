@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const bdiffSummaryFileName = "bdiff/summary"
+
+// bdiffSummaryEntry is the result of a table of target datasource compared to a base
+type bdiffSummaryEntry struct {
+	Table      string `json:"table"`
+	Base       string `json:"base"`
+	DataSource string `json:"dataSource"`
+	BaseRows   int    `json:"baseRows"`
+	Rows       int    `json:"rows"`
+	Missing    int    `json:"missing"`
+	Extra      int    `json:"extra"`
+	Diff       int    `json:"diff"`
+	Normalized int    `json:"normalized"`
+	Tie        int    `json:"tie"`
+	DiffTable  bool   `json:"diffTable"`
+	Skipped    string `json:"skipped,omitempty"`
+	Error      string `json:"error,omitempty"`
+	CsvFile    string `json:"csvFile,omitempty"`
+	// BaseSnapshotAt and SnapshotAt are the time when consistent snapshots are opened
+	BaseSnapshotAt string `json:"baseSnapshotAt,omitempty"`
+	SnapshotAt     string `json:"snapshotAt,omitempty"`
+}
+
+// count adds the csv records of the entry datasource to the counters
+func (e *bdiffSummaryEntry) count(records [][]string) {
+	for _, record := range records {
+		if record[1] != e.DataSource {
+			continue
+		}
+		switch record[2] {
+		case "MISSING":
+			e.Missing++
+		case "EXTRA":
+			e.Extra++
+		case "DIFF":
+			e.Diff++
+		case "NORMALIZED":
+			e.Normalized++
+		case "TIE":
+			e.Tie++
+		case "DIFF_TABLE":
+			e.DiffTable = true
+		}
+	}
+}
+
+// bdiffSummary collects the entries of all bdiff jobs in the order they are created,
+// every entry is only updated by the job which compares it
+type bdiffSummary struct {
+	mu      sync.Mutex
+	entries []*bdiffSummaryEntry
+}
+
+func (s *bdiffSummary) Entry(table, base, dsKey, csvFile string) *bdiffSummaryEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.Table == table && e.Base == base && e.DataSource == dsKey {
+			return e
+		}
+	}
+	e := &bdiffSummaryEntry{Table: table, Base: base, DataSource: dsKey, CsvFile: csvFile}
+	s.entries = append(s.entries, e)
+	return e
+}
+
+// Save writes the entries to fileName with .csv and .json extensions
+func (s *bdiffSummary) Save(fileName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName+".json", data, 0665); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(fileName+".csv", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	_ = w.Write([]string{"Table", "Base", "DataSource", "BaseRows", "Rows", "Missing", "Extra", "Diff", "Normalized",
+		"Tie", "DiffTable", "Skipped", "Error", "CsvFile", "BaseSnapshotAt", "SnapshotAt"})
+	for _, e := range s.entries {
+		_ = w.Write([]string{e.Table, e.Base, e.DataSource, strconv.Itoa(e.BaseRows), strconv.Itoa(e.Rows),
+			strconv.Itoa(e.Missing), strconv.Itoa(e.Extra), strconv.Itoa(e.Diff), strconv.Itoa(e.Normalized),
+			strconv.Itoa(e.Tie), strconv.FormatBool(e.DiffTable), e.Skipped, e.Error, e.CsvFile, e.BaseSnapshotAt, e.SnapshotAt})
+	}
+	w.Flush()
+	return w.Error()
+}
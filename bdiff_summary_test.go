@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestBdiffSummaryAndHtml(t *testing.T) {
+	as := assert.New(t)
+	s := newFixtureSqler(t)
+	_, err := s.dbs[1].Exec(`update a set a1 = '<script>alert("x")</script>' where id = 5`)
+	as.NoError(err)
+	job := NewBdiffJob(s, []string{"a", "b"}, &BdiffOptions{Html: true})
+	job.Exec()
+	as.NoError(job.Error())
+
+	data, err := os.ReadFile(bdiffSummaryFileName + ".json")
+	as.NoError(err)
+	var entries []*bdiffSummaryEntry
+	as.NoError(json.Unmarshal(data, &entries))
+	counts := make(map[string][]int)
+	for _, e := range entries {
+		counts[e.Table+" "+e.DataSource] = []int{e.BaseRows, e.Rows, e.Missing, e.Extra, e.Diff, e.Normalized}
+	}
+	as.Equal(map[string][]int{
+		"a /db_01": {4, 3, 1, 0, 1, 0},
+		"a /db_02": {4, 4, 0, 0, 1, 0},
+		"b /db_01": {2, 1, 1, 0, 0, 1},
+		"b /db_02": {2, 1, 1, 0, 0, 1},
+	}, counts)
+
+	// Cell values are escaped
+	data, err = os.ReadFile(bdiffHtmlFileName)
+	as.NoError(err)
+	as.Contains(string(data), "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;")
+	as.NotContains(string(data), "<script>")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sqler/pkg"
+)
+
+// BdiffTableJob compares one table of one datasource to the shared base data of another datasource
+type BdiffTableJob struct {
+	sqler     *Sqler
+	base      *bdiffBase
+	dbIdx     int
+	tableId   int
+	tableSize int
+	batchRow  int
+	entry     *bdiffSummaryEntry
+	*BaseJob
+}
+
+func NewBdiffTableJob(sqler *Sqler, base *bdiffBase, dbIdx int, tableId int, tableSize int, batchRow int, entry *bdiffSummaryEntry) Job {
+	return &BdiffTableJob{
+		sqler:     sqler,
+		base:      base,
+		dbIdx:     dbIdx,
+		tableId:   tableId,
+		tableSize: tableSize,
+		batchRow:  batchRow,
+		entry:     entry,
+		BaseJob:   NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *BdiffTableJob) BeforeExec() {
+	job.PrintBeforeExec(fmt.Sprintf("[%s] Comparing table %s (%d/%d) at db %s (%d/%d) ... ", pkg.Now(),
+		job.base.table.name, job.tableId, job.tableSize, job.dsKey(), job.dbIdx+1, len(job.sqler.dbs)))
+}
+
+func (job *BdiffTableJob) Exec() {
+	defer func() {
+		job.RecordError(job.base.release())
+	}()
+	if err := job.base.load(); err != nil {
+		job.RecordError(err)
+		job.entry.Error = err.Error()
+		return
+	}
+	job.entry.BaseRows = len(job.base.rowMap)
+	if job.base.skipReason != "" {
+		job.entry.Skipped = job.base.skipReason
+		job.PrintAfterDone(fmt.Sprintf("[%s] Skip comparsion at db %s because of %s", pkg.Now(), job.dsKey(), job.base.skipReason))
+		return
+	}
+	if err := compare(job.base, job.dsKey(), job.sqler.Reader(job.dbIdx), job.sqler.Dialect(job.dbIdx), job.batchRow, job.entry); err != nil {
+		job.entry.Error = err.Error()
+		unit := bdiffUnit{Table: job.base.table.name, Base: job.base.name, Target: job.dsKey()}
+		job.RecordError(fmt.Errorf("failed to compare %s: %w", unit, err))
+		job.RecordError(job.base.state.MarkFailed(unit, err))
+		return
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Compared table %s (%d/%d) at db %s (%d/%d) ... ", pkg.Now(),
+		job.base.table.name, job.tableId, job.tableSize, job.dsKey(), job.dbIdx+1, len(job.sqler.dbs)))
+}
+
+func (job *BdiffTableJob) dsKey() string {
+	return job.sqler.cfg.DataSources[job.dbIdx].DsKey()
+}
@@ -1,91 +1,258 @@
 package main
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
+	"sqler/pkg"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// countAbsent is the count of table which does not exist in datasource
+	countAbsent = "absent"
+	countError  = "ERROR"
 )
 
-func NewCountJob(sqler *Sqler, csvFileName string, schemas []string) Job {
+func NewCountJob(sqler *Sqler, csvFileName string, schemas []string, where string) Job {
 	return &CountJob{
 		sqler:       sqler,
 		csvFileName: csvFileName,
 		schemas:     schemas,
+		where:       where,
 		BaseJob:     NewBaseJob(new(JobCtx)),
 	}
 }
 
+// CountJob counts the rows of tables at all datasources concurrently, the counts which differ
+// from the first datasource are flagged. If no table is given, the count-schemas of config are
+// counted, or all tables found in the catalogs of datasources
 type CountJob struct {
 	sqler       *Sqler
 	csvFileName string
 	schemas     []string
+	where       string
 	*BaseJob
 }
 
 func (job *CountJob) Exec() {
-	// ds - ds - count
-	schemaDsCountMap := make(map[string]map[string]string)
-	for _, schema := range job.schemas {
-		schemaDsCountMap[schema] = make(map[string]string)
-	}
-	errs := make([]error, 0)
-	for dbID, db := range sqler.dbs {
-		ds := sqler.cfg.DataSources[dbID]
-		for _, schema := range job.schemas {
-			cntQuery := "select count(*) from " + schema
-			results, err := db.Query(cntQuery)
-			if err != nil {
-				errs = append(errs, err)
-				continue
-			}
-			_, rows, err := convertSqlResults(results)
-			if err != nil {
-				errs = append(errs, err)
-				continue
+	dataSources := job.sqler.cfg.DataSources
+	dbSize := len(job.sqler.dbs)
+
+	// Tables of datasources keyed by lower case names, nil if the catalog can not be read
+	catalogJobs := make([]*CountCatalogJob, dbSize)
+	job.execOnEachDb(func(dbIdx int) Job {
+		catalogJobs[dbIdx] = NewCountCatalogJob(job.sqler, dbIdx)
+		return catalogJobs[dbIdx]
+	})
+	catalogs := make([]map[string]string, dbSize)
+	for dbIdx, catalogJob := range catalogJobs {
+		catalogs[dbIdx] = catalogJob.tables
+	}
+	schemas := job.schemas
+	if len(schemas) == 0 {
+		schemas = job.sqler.cfg.CommandsConfig.CountSchemas
+	}
+	if len(schemas) == 0 {
+		seen := make(map[string]bool)
+		for _, catalog := range catalogs {
+			for key, table := range catalog {
+				if !seen[key] {
+					seen[key] = true
+					schemas = append(schemas, table)
+				}
 			}
-			schemaDsCountMap[schema][ds.DsKey()] = rows[0][0]
 		}
-		fmt.Printf("Count db %d/%d %s\n", dbID+1, len(sqler.dbs), ds.DsKey())
+		sort.Strings(schemas)
+	}
+	if len(schemas) == 0 {
+		job.RecordError(fmt.Errorf("no table to count"))
+		return
 	}
 
-	if len(errs) > 0 {
-		for _, err := range errs {
-			job.RecordError(err)
+	// counts[table][dbIdx]
+	counts := make([][]string, len(schemas))
+	for i := range counts {
+		counts[i] = make([]string, dbSize)
+	}
+	where := ""
+	if job.where != "" {
+		where = " where " + job.where
+	}
+	countJobs := make([]*CountDbJob, dbSize)
+	job.execOnEachDb(func(dbIdx int) Job {
+		countJobs[dbIdx] = NewCountDbJob(job.sqler, dbIdx, schemas, catalogs[dbIdx], where)
+		return countJobs[dbIdx]
+	})
+	// The errors of datasources are printed by their jobs
+	failed := 0
+	for dbIdx, countJob := range countJobs {
+		for i, count := range countJob.counts {
+			counts[i][dbIdx] = count
 		}
-		return
+		if countJob.Error() != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		job.RecordError(fmt.Errorf("failed to count tables at %d/%d datasources", failed, dbSize))
 	}
 
 	// Csv
 	file, err := os.OpenFile(job.csvFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		panic(err)
+	if job.RecordError(err) {
+		return
 	}
 	defer file.Close()
 	csvWriter := csv.NewWriter(file)
-
-	header := make([]string, 0, len(job.schemas)+1)
+	header := make([]string, 0, dbSize+2)
 	header = append(header, "Tables")
-	for _, ds := range job.sqler.cfg.DataSources {
+	for _, ds := range dataSources {
 		header = append(header, ds.DsKey())
 	}
-	if err := csvWriter.Write(header); err != nil {
-		job.RecordError(err)
+	_ = csvWriter.Write(append(header, "Mismatch"))
+
+	b := new(bytes.Buffer)
+	table := tablewriter.NewWriter(b)
+	table.SetHeader(append(header, "Mismatch"))
+	table.SetAutoFormatHeaders(false)
+	mismatchTables := 0
+	for i, schema := range schemas {
+		row, cells := []string{schema}, []string{schema}
+		mismatch := make([]string, 0)
+		for dbIdx, count := range counts[i] {
+			row = append(row, count)
+			// Flag the counts which differ from the base datasource
+			if dbIdx > 0 && count != counts[i][0] {
+				mismatch = append(mismatch, dataSources[dbIdx].DsKey())
+				count += " *"
+			}
+			cells = append(cells, count)
+		}
+		if len(mismatch) > 0 {
+			mismatchTables++
+		}
+		_ = csvWriter.Write(append(row, strings.Join(mismatch, " ")))
+		table.Append(append(cells, strings.Join(mismatch, " ")))
+	}
+	// Counts are read from the snapshots opened at these moments
+	if job.sqler.snapshots != nil {
+		snapshotRow := []string{"Snapshot At"}
+		for dbIdx := range dataSources {
+			snapshotRow = append(snapshotRow, job.sqler.SnapshotAt(dbIdx))
+		}
+		_ = csvWriter.Write(append(snapshotRow, ""))
+	}
+	csvWriter.Flush()
+	if job.RecordError(csvWriter.Error()) {
 		return
 	}
+	table.Render()
+	job.PrintAfterDone(b.String())
+	job.PrintAfterDone(fmt.Sprintf("[%s] Counted %d tables, %d tables differ from %s (*), result saved to %s",
+		pkg.Now(), len(schemas), mismatchTables, dataSources[0].DsKey(), job.csvFileName))
+}
 
-	for _, schema := range job.schemas {
-		tableRow := make([]string, 0, len(sqler.cfg.DataSources)+1)
-		tableRow = append(tableRow, schema)
-		for _, ds := range sqler.cfg.DataSources {
-			tableRow = append(tableRow, schemaDsCountMap[schema][ds.DsKey()])
+// execOnEachDb executes the job of every datasource created by newJob in its own lane and waits for them done
+func (job *CountJob) execOnEachDb(newJob func(dbIdx int) Job) {
+	jobExecutor := NewJobExecutor(len(job.sqler.dbs))
+	jobExecutor.Start()
+	for dbIdx := range job.sqler.dbs {
+		jobExecutor.Submit(newJob(dbIdx), dbIdx)
+	}
+	jobExecutor.Shutdown(true)
+}
+
+// CountCatalogJob lists the tables of one datasource, tables is nil if the catalog can not be read
+type CountCatalogJob struct {
+	sqler *Sqler
+	dbIdx int
+	// tables are the names of tables keyed by lower case names
+	tables map[string]string
+	*BaseJob
+}
+
+func NewCountCatalogJob(sqler *Sqler, dbIdx int) *CountCatalogJob {
+	return &CountCatalogJob{
+		sqler:   sqler,
+		dbIdx:   dbIdx,
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *CountCatalogJob) Exec() {
+	ds := job.sqler.cfg.DataSources[job.dbIdx]
+	tables, err := listTables(job.sqler.Reader(job.dbIdx), job.sqler.Dialect(job.dbIdx), ds.Schema)
+	if err != nil {
+		printer.Log(fmt.Sprintf("Failed to list tables of %s: %v", ds.DsKey(), err))
+		return
+	}
+	job.tables = make(map[string]string, len(tables))
+	for _, table := range tables {
+		job.tables[strings.ToLower(table)] = table
+	}
+}
+
+// CountDbJob counts the rows of tables at one datasource, the tables are matched to the catalog
+// case-insensitively and the tables not in it are absent
+type CountDbJob struct {
+	sqler   *Sqler
+	dbIdx   int
+	schemas []string
+	catalog map[string]string
+	where   string
+	// counts are the counts of schemas
+	counts []string
+	*BaseJob
+}
+
+func NewCountDbJob(sqler *Sqler, dbIdx int, schemas []string, catalog map[string]string, where string) *CountDbJob {
+	return &CountDbJob{
+		sqler:   sqler,
+		dbIdx:   dbIdx,
+		schemas: schemas,
+		catalog: catalog,
+		where:   where,
+		counts:  make([]string, len(schemas)),
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *CountDbJob) Exec() {
+	ds := job.sqler.cfg.DataSources[job.dbIdx]
+	dialect := job.sqler.Dialect(job.dbIdx)
+	for i, schema := range job.schemas {
+		table := schema
+		if job.catalog != nil {
+			var ok bool
+			if table, ok = job.catalog[strings.ToLower(schema)]; !ok {
+				job.counts[i] = countAbsent
+				continue
+			}
 		}
-		// Csv content
-		if err := csvWriter.Write(tableRow); err != nil {
-			job.RecordError(err)
-			return
+		_, rows, err := queryAsString(job.sqler.Reader(job.dbIdx), "select count(*) from "+dialect.QuoteIdent(table)+job.where)
+		if err != nil {
+			job.counts[i] = countError
+			job.RecordError(fmt.Errorf("failed to count %s at %s: %w", schema, ds.DsKey(), err))
+			continue
 		}
-		csvWriter.Flush()
+		job.counts[i] = rows[0][0]
+	}
+	job.PrintAfterDone(fmt.Sprintf("Count db %d/%d %s", job.dbIdx+1, len(job.sqler.dbs), ds.DsKey()))
+}
+
+// parseCountArgs parses args like "table_1 table_2 where id > 10"
+func parseCountArgs(args []string) ([]string, string) {
+	idx := slices.IndexFunc(args, func(arg string) bool {
+		return strings.EqualFold(arg, "where")
+	})
+	if idx < 0 {
+		return args, ""
 	}
-	fmt.Println("Result saved to " + file.Name())
-	return
+	return args[:idx], strings.Join(args[idx+1:], " ")
 }
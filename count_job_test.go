@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestParseCountArgs(t *testing.T) {
+	as := assert.New(t)
+	tables, where := parseCountArgs([]string{"a", "b", "WHERE", "id", ">", "10"})
+	as.Equal([]string{"a", "b"}, tables)
+	as.Equal("id > 10", where)
+	tables, where = parseCountArgs([]string{"a"})
+	as.Equal([]string{"a"}, tables)
+	as.Empty(where)
+	tables, where = parseCountArgs([]string{"where", "deleted = 0"})
+	as.Empty(tables)
+	as.Equal("deleted = 0", where)
+}
+
+func TestCountJob(t *testing.T) {
+	as := assert.New(t)
+	s := newFixtureSqler(t)
+	for dbIdx, stmt := range []string{
+		`create table "Users" (id int); insert into "Users" values (1)`,
+		`create table "Users" (id int); alter table a rename column a3 to a9`,
+		`drop table b`,
+	} {
+		_, err := s.dbs[dbIdx].Exec(stmt)
+		as.NoError(err)
+	}
+	count := func(schemas []string, where string) ([][]string, error) {
+		job := NewCountJob(s, "count.csv", schemas, where)
+		job.Exec()
+		file, err := os.Open("count.csv")
+		as.NoError(err)
+		defer file.Close()
+		records, err := csv.NewReader(file).ReadAll()
+		as.NoError(err)
+		return records, job.Error()
+	}
+
+	records, err := count([]string{"users", "B"}, "")
+	as.NoError(err)
+	as.Equal([][]string{
+		{"Tables", "/db_base", "/db_01", "/db_02", "Mismatch"},
+		{"users", "1", "0", countAbsent, "/db_01 /db_02"},
+		{"B", "2", "2", countAbsent, "/db_02"},
+	}, records)
+
+	records, err = count([]string{"a"}, "a3 >= 0")
+	as.ErrorContains(err, "failed to count tables at 1/3 datasources")
+	as.Equal([]string{"a", "4", countError, "4", "/db_01"}, records[1])
+}
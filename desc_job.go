@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sqler/pkg"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// DescJob shows the columns, indexes and DDL of a table. The first datasource which has the table
+// is the base, the cells which other datasources disagree on are flagged with the differences
+type DescJob struct {
+	sqler *Sqler
+	table string
+	*BaseJob
+}
+
+func NewDescJob(sqler *Sqler, table string) Job {
+	return &DescJob{
+		sqler:   sqler,
+		table:   table,
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+// descRows are the rows of columns or indexes in the order of base, notes are the differences of rows
+type descRows struct {
+	names []string
+	rows  map[string][]string
+	notes map[string][]string
+}
+
+func newDescRows() *descRows {
+	return &descRows{rows: make(map[string][]string), notes: make(map[string][]string)}
+}
+
+func (r *descRows) add(name string, row []string) {
+	if _, ok := r.rows[name]; !ok {
+		r.names = append(r.names, name)
+		r.rows[name] = row
+	}
+}
+
+// mark flags the cell of row and notes the difference
+func (r *descRows) mark(name string, cell int, note string) {
+	if !strings.HasSuffix(r.rows[name][cell], " *") {
+		r.rows[name][cell] += " *"
+	}
+	r.notes[name] = append(r.notes[name], note)
+}
+
+// cells returns the rows with the differences as the last cell
+func (r *descRows) cells() [][]string {
+	cells := make([][]string, 0, len(r.names))
+	for _, name := range r.names {
+		cells = append(cells, append(slices.Clip(r.rows[name]), strings.Join(r.notes[name], "; ")))
+	}
+	return cells
+}
+
+func (r *descRows) render(b *bytes.Buffer, header []string) {
+	table := tablewriter.NewWriter(b)
+	table.SetHeader(append(header, "Differences"))
+	table.SetAutoFormatHeaders(false)
+	table.AppendBulk(r.cells())
+	table.Render()
+}
+
+// descColumnCells are the cells of column attributes compared by diffSchemaTable
+var descColumnCells = map[string]int{"TYPE": 1, "NULLABLE": 2, "DEFAULT": 3, "EXTRA": 4, "COMMENT": 5}
+
+var (
+	descColumnHeader = []string{"Column", "Type", "Nullable", "Default", "Extra", "Comment"}
+	descIndexHeader  = []string{"Index", "Columns", "Unique", "Type"}
+)
+
+// tableDescription is the table of base datasource, the columns and indexes which other
+// datasources disagree on are flagged
+type tableDescription struct {
+	baseIdx int
+	base    *SchemaTable
+	// notes are the differences of table attributes and the datasources without the table
+	notes   []string
+	columns *descRows
+	indexes *descRows
+}
+
+// describeTable compares table in schemas of dataSources, nil if no datasource has it
+func describeTable(schemas []map[string]*SchemaTable, dataSources []*pkg.DataSourceConfig, table string) *tableDescription {
+	desc := &tableDescription{baseIdx: -1, notes: make([]string, 0), columns: newDescRows(), indexes: newDescRows()}
+	for dbIdx, tables := range schemas {
+		if tables[table] != nil {
+			desc.baseIdx, desc.base = dbIdx, tables[table]
+			break
+		}
+	}
+	if desc.base == nil {
+		return nil
+	}
+	base := desc.base
+	columnRow := func(c *SchemaColumn) []string {
+		return []string{c.Name, c.Type, strconv.FormatBool(c.Nullable), defaultString(c.Default), c.Extra, c.Comment}
+	}
+	indexRow := func(idx *SchemaIndex) []string {
+		return []string{idx.Name, strings.Join(idx.Columns, ","), strconv.FormatBool(idx.Unique), idx.Type}
+	}
+	columns, indexes := desc.columns, desc.indexes
+	for _, c := range base.Columns {
+		columns.add(c.Name, columnRow(c))
+	}
+	for _, idx := range base.Indexes {
+		indexes.add(idx.Name, indexRow(idx))
+	}
+
+	for dbIdx, tables := range schemas {
+		if dbIdx == desc.baseIdx {
+			continue
+		}
+		t, dsName := tables[table], dataSources[dbIdx].Name()
+		if tables == nil {
+			desc.notes = append(desc.notes, dsName+": "+countError)
+			continue
+		}
+		if t == nil {
+			desc.notes = append(desc.notes, dsName+": "+countAbsent)
+			continue
+		}
+		diffs, _ := diffSchemaTable(dialectOf(dataSources[dbIdx].Type), base, t)
+		for _, d := range diffs {
+			switch {
+			case d.Object == "TABLE":
+				desc.notes = append(desc.notes, fmt.Sprintf("%s: %s=%s", dsName, d.Attribute, d.Value))
+			// EXTRA is also an attribute of column
+			case d.Object == "COLUMN" && base.Column(d.Name) == nil:
+				columns.add(d.Name, columnRow(t.Column(d.Name)))
+				columns.mark(d.Name, 0, dsName+": extra")
+			case d.Object == "COLUMN" && d.Attribute == "MISSING":
+				columns.mark(d.Name, 0, dsName+": missing")
+			case d.Object == "COLUMN":
+				columns.mark(d.Name, descColumnCells[d.Attribute], fmt.Sprintf("%s: %s=%s", dsName, d.Attribute, d.Value))
+			case d.Attribute == "EXTRA":
+				indexes.add(d.Name, indexRow(t.Index(d.Name)))
+				indexes.mark(d.Name, 0, dsName+": extra")
+			case d.Attribute == "MISSING":
+				indexes.mark(d.Name, 0, dsName+": missing")
+			default:
+				indexes.mark(d.Name, 1, fmt.Sprintf("%s: %s", dsName, d.Value))
+			}
+		}
+	}
+	return desc
+}
+
+func (job *DescJob) Exec() {
+	// The datasources failed to load are flagged with ERROR and their errors recorded after printing
+	schemas, loadErr := loadAllSchemaTables(job.sqler)
+	if !anySchemaLoaded(schemas) {
+		job.RecordError(loadErr)
+		return
+	}
+	dataSources := job.sqler.cfg.DataSources
+	desc := describeTable(schemas, dataSources, job.table)
+	if desc == nil {
+		job.RecordError(errors.Join(loadErr, fmt.Errorf("table %s not found", job.table)))
+		return
+	}
+	base, baseIdx := desc.base, desc.baseIdx
+
+	b := new(bytes.Buffer)
+	title := "Table " + job.table
+	if base.Comment != "" {
+		title += " (" + base.Comment + ")"
+	}
+	b.WriteString(fmt.Sprintf("%s, base datasource %s\n", title, dataSources[baseIdx].Name()))
+	for _, note := range desc.notes {
+		b.WriteString("  * " + note + "\n")
+	}
+	desc.columns.render(b, descColumnHeader)
+	desc.indexes.render(b, descIndexHeader)
+
+	// Datasources which have the same DDL are shown together
+	ddls := make([]string, 0)
+	ddlDataSources := make(map[string][]string)
+	for dbIdx, tables := range schemas {
+		if tables[job.table] == nil {
+			continue
+		}
+		query, args := job.sqler.Dialect(dbIdx).CreateTableQuery(job.table)
+		_, rows, err := queryAsString(job.sqler.Reader(dbIdx), query, args...)
+		if job.RecordError(err) {
+			return
+		}
+		if len(rows) == 0 || len(rows[0]) < 2 {
+			continue
+		}
+		ddl := rows[0][1]
+		if _, ok := ddlDataSources[ddl]; !ok {
+			ddls = append(ddls, ddl)
+		}
+		ddlDataSources[ddl] = append(ddlDataSources[ddl], dataSources[dbIdx].Name())
+	}
+	for _, ddl := range ddls {
+		b.WriteString(fmt.Sprintf("-- DDL of %s\n%s;\n", strings.Join(ddlDataSources[ddl], " "), ddl))
+	}
+	job.PrintAfterDone(b.String())
+	job.PrintAfterDone(fmt.Sprintf("[%s] Described table %s of %d datasources, differences are flagged (*)",
+		pkg.Now(), job.table, len(dataSources)))
+	job.RecordError(loadErr)
+}
+
+func dataSourceNames(dataSources []*pkg.DataSourceConfig) []string {
+	names := make([]string, len(dataSources))
+	for i, ds := range dataSources {
+		names[i] = ds.Name()
+	}
+	return names
+}
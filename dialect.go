@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// Dialect builds the SQL which differs between database types, jobs go through the dialect of
+// datasource instead of writing MySQL syntax
+type Dialect interface {
+	// QuoteIdent quotes identifier, each part of a qualified name like schema.table is quoted
+	QuoteIdent(name string) string
+	// Literal formats v as a string literal
+	Literal(v string) string
+	// BitValue returns the value of a BIT column as decimal text
+	BitValue(v string) string
+	// Paging appends limit and offset to query
+	Paging(query string, limit int, offset int) string
+	// Explain returns the statement which shows the plan of query
+	Explain(query string) string
+	// TableNamesQuery returns the query and args which list the names of base tables in schema
+	TableNamesQuery(schema string) (string, []any)
+	// TableMetasQuery returns the query and args which list the names and comments of tables
+	TableMetasQuery(schema string) (string, []any)
+	// ColumnMetasQuery returns the query and args which list the tables, names, comments and types of columns
+	ColumnMetasQuery(schema string) (string, []any)
+	// SchemaQueries returns the queries of tables, columns and indexes read by loadSchemaTables,
+	// and the args of them
+	SchemaQueries(schema string) (tables string, columns string, indexes string, args []any)
+	// CreateTableQuery returns the query and args of the table name and DDL of table
+	CreateTableQuery(table string) (string, []any)
+
+	// The DDL of schema diff scripts, the changes which the database cannot make by a statement
+	// are returned as comments which tell how to make them
+
+	// ColumnOptions returns the options after the default of column definition, e.g. comment
+	ColumnOptions(c *SchemaColumn) string
+	// CreateTableSql returns the statements which create t with the columns and indexes of it
+	CreateTableSql(t *SchemaTable) string
+	// AddColumnSql returns the statement which adds c to table after the column named after,
+	// or first if after is empty
+	AddColumnSql(table string, c *SchemaColumn, after string) string
+	// ModifyColumnSql returns the statement which changes the column of table to the definition of c
+	ModifyColumnSql(table string, c *SchemaColumn) string
+	DropColumnSql(table string, column string) string
+	AddIndexSql(table string, idx *SchemaIndex) string
+	// ReplaceIndexSql returns the statements which replace the index of the same name by idx
+	ReplaceIndexSql(table string, idx *SchemaIndex) string
+	DropIndexSql(table string, idx *SchemaIndex) string
+	// TableOptionsSql returns the statement which sets the attributes of t, e.g. ENGINE and COMMENT
+	TableOptionsSql(t *SchemaTable, attributes []string) string
+}
+
+var dialects = map[string]Dialect{
+	"mysql":   mysqlDialect{},
+	"sqlite3": sqliteDialect{},
+}
+
+// dialectOf returns the dialect of datasource type, see ConnJob.connect for the supported types
+func dialectOf(dsType string) Dialect {
+	d, ok := dialects[dsType]
+	if !ok {
+		panic("Not supported database type: " + dsType)
+	}
+	return d
+}
+
+// Dialect returns the dialect of datasource
+func (s *Sqler) Dialect(dbIdx int) Dialect {
+	return dialectOf(s.cfg.DataSources[dbIdx].Type)
+}
+
+// DataSourceTypes returns the distinct types of datasources
+func (s *Sqler) DataSourceTypes() []string {
+	types := make([]string, 0, 1)
+	for _, ds := range s.cfg.DataSources {
+		if !slices.Contains(types, ds.Type) {
+			types = append(types, ds.Type)
+		}
+	}
+	return types
+}
+
+// quoteIdent quotes the parts of name by quote, names which are already quoted and * are kept
+func quoteIdent(name string, quote string) string {
+	if name == "*" || strings.HasPrefix(name, quote) {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteIdents quotes names and joins them by comma
+func quoteIdents(d Dialect, names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = d.QuoteIdent(name)
+	}
+	return strings.Join(quoted, ",")
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return quoteIdent(name, "`")
+}
+
+// Literal escapes backslashes which MySQL treats as escape chars in string literals
+func (mysqlDialect) Literal(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// BitValue decodes v, MySQL returns bit values as big endian bytes
+func (mysqlDialect) BitValue(v string) string {
+	return new(big.Int).SetBytes([]byte(v)).String()
+}
+
+func (mysqlDialect) Paging(query string, limit int, offset int) string {
+	return fmt.Sprintf("%s limit %d offset %d", query, limit, offset)
+}
+
+func (mysqlDialect) Explain(query string) string {
+	return "explain " + query
+}
+
+func (mysqlDialect) TableNamesQuery(schema string) (string, []any) {
+	return stmtQueryTableNames, []any{schema}
+}
+
+func (mysqlDialect) TableMetasQuery(schema string) (string, []any) {
+	return stmtQueryTableMetas, []any{schema}
+}
+
+func (mysqlDialect) ColumnMetasQuery(schema string) (string, []any) {
+	return stmtQueryColumnMetas, []any{schema}
+}
+
+func (mysqlDialect) SchemaQueries(schema string) (string, string, string, []any) {
+	return stmtQuerySchemaTables, stmtQuerySchemaColumns, stmtQuerySchemaIndexes, []any{schema}
+}
+
+func (d mysqlDialect) CreateTableQuery(table string) (string, []any) {
+	return "show create table " + d.QuoteIdent(table), nil
+}
+
+// sqliteDialect reads the catalog of the main database, schema is the file name of datasource
+type sqliteDialect struct{}
+
+func (sqliteDialect) QuoteIdent(name string) string {
+	return quoteIdent(name, `"`)
+}
+
+func (sqliteDialect) Literal(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// BitValue returns v, SQLite has no bit type and returns the integer stored as decimal text
+func (sqliteDialect) BitValue(v string) string {
+	return canonicalNumber(v)
+}
+
+func (sqliteDialect) Paging(query string, limit int, offset int) string {
+	return fmt.Sprintf("%s limit %d offset %d", query, limit, offset)
+}
+
+func (sqliteDialect) Explain(query string) string {
+	return "explain query plan " + query
+}
+
+func (sqliteDialect) TableNamesQuery(string) (string, []any) {
+	return stmtQueryTableNamesSqlite, nil
+}
+
+func (sqliteDialect) TableMetasQuery(string) (string, []any) {
+	return stmtQueryTableMetasSqlite, nil
+}
+
+func (sqliteDialect) ColumnMetasQuery(string) (string, []any) {
+	return stmtQueryColumnMetasSqlite, nil
+}
+
+// SchemaQueries reads the columns and indexes by pragma, the primary key is named PRIMARY like
+// MySQL and SQLite has no comments, engines or sizes
+func (sqliteDialect) SchemaQueries(string) (string, string, string, []any) {
+	return stmtQuerySchemaTablesSqlite, stmtQuerySchemaColumnsSqlite, stmtQuerySchemaIndexesSqlite, nil
+}
+
+func (sqliteDialect) CreateTableQuery(table string) (string, []any) {
+	return "select name, sql from sqlite_master where type = 'table' and name = ?", []any{table}
+}
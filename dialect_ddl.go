@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (d mysqlDialect) ColumnOptions(c *SchemaColumn) string {
+	var sb strings.Builder
+	if extra := strings.TrimSpace(strings.ReplaceAll(c.Extra, "DEFAULT_GENERATED", "")); extra != "" {
+		sb.WriteString(" " + extra)
+	}
+	if c.Comment != "" {
+		sb.WriteString(" COMMENT " + d.Literal(c.Comment))
+	}
+	return sb.String()
+}
+
+func (d mysqlDialect) CreateTableSql(t *SchemaTable) string {
+	defs := make([]string, 0, len(t.Columns)+len(t.Indexes))
+	for _, col := range t.Columns {
+		defs = append(defs, "  "+col.Definition(d))
+	}
+	for _, idx := range t.Indexes {
+		defs = append(defs, "  "+idx.Definition(d))
+	}
+	var sb strings.Builder
+	sb.WriteString("CREATE TABLE " + d.QuoteIdent(t.Name) + " (\n")
+	sb.WriteString(strings.Join(defs, ",\n"))
+	sb.WriteString("\n)")
+	if t.Engine != "" {
+		sb.WriteString(" ENGINE=" + t.Engine)
+	}
+	if t.Collation != "" {
+		sb.WriteString(" COLLATE=" + t.Collation)
+	}
+	if t.Comment != "" {
+		sb.WriteString(" COMMENT=" + d.Literal(t.Comment))
+	}
+	sb.WriteString(";")
+	return sb.String()
+}
+
+func (d mysqlDialect) AddColumnSql(table string, c *SchemaColumn, after string) string {
+	position := " FIRST"
+	if after != "" {
+		position = " AFTER " + d.QuoteIdent(after)
+	}
+	return "ALTER TABLE " + d.QuoteIdent(table) + " ADD COLUMN " + c.Definition(d) + position + ";"
+}
+
+func (d mysqlDialect) ModifyColumnSql(table string, c *SchemaColumn) string {
+	return "ALTER TABLE " + d.QuoteIdent(table) + " MODIFY COLUMN " + c.Definition(d) + ";"
+}
+
+func (d mysqlDialect) DropColumnSql(table string, column string) string {
+	return "ALTER TABLE " + d.QuoteIdent(table) + " DROP COLUMN " + d.QuoteIdent(column) + ";"
+}
+
+func (d mysqlDialect) AddIndexSql(table string, idx *SchemaIndex) string {
+	return "ALTER TABLE " + d.QuoteIdent(table) + " ADD " + idx.Definition(d) + ";"
+}
+
+func (d mysqlDialect) ReplaceIndexSql(table string, idx *SchemaIndex) string {
+	return "ALTER TABLE " + d.QuoteIdent(table) + " " + d.dropIndexClause(idx) + ", ADD " + idx.Definition(d) + ";"
+}
+
+func (d mysqlDialect) DropIndexSql(table string, idx *SchemaIndex) string {
+	return "ALTER TABLE " + d.QuoteIdent(table) + " " + d.dropIndexClause(idx) + ";"
+}
+
+func (d mysqlDialect) dropIndexClause(idx *SchemaIndex) string {
+	if idx.Name == "PRIMARY" {
+		return "DROP PRIMARY KEY"
+	}
+	return "DROP INDEX " + d.QuoteIdent(idx.Name)
+}
+
+func (d mysqlDialect) TableOptionsSql(t *SchemaTable, attributes []string) string {
+	options := make([]string, 0, len(attributes))
+	for _, attribute := range attributes {
+		switch attribute {
+		case "ENGINE":
+			options = append(options, "ENGINE="+t.Engine)
+		case "COLLATION":
+			options = append(options, "COLLATE="+t.Collation)
+		case "COMMENT":
+			options = append(options, "COMMENT="+d.Literal(t.Comment))
+		}
+	}
+	return "ALTER TABLE " + d.QuoteIdent(t.Name) + " " + strings.Join(options, " ") + ";"
+}
+
+// ColumnOptions is empty since SQLite has no comments, AUTOINCREMENT is declared with the primary
+// key by CreateTableSql
+func (sqliteDialect) ColumnOptions(*SchemaColumn) string {
+	return ""
+}
+
+// CreateTableSql declares the primary key in the table and creates the other indexes after it, the
+// auto increment primary key is declared with the column as SQLite requires
+func (d sqliteDialect) CreateTableSql(t *SchemaTable) string {
+	defs := make([]string, 0, len(t.Columns)+1)
+	primary := t.Index("PRIMARY")
+	for _, col := range t.Columns {
+		if primary != nil && len(primary.Columns) == 1 && primary.Columns[0] == col.Name && strings.Contains(col.Extra, "auto_increment") {
+			defs = append(defs, "  "+d.QuoteIdent(col.Name)+" "+col.Type+" PRIMARY KEY AUTOINCREMENT")
+			primary = nil
+			continue
+		}
+		defs = append(defs, "  "+col.Definition(d))
+	}
+	if primary != nil {
+		defs = append(defs, "  "+primary.Definition(d))
+	}
+	stmts := []string{"CREATE TABLE " + d.QuoteIdent(t.Name) + " (\n" + strings.Join(defs, ",\n") + "\n);"}
+	for _, idx := range t.Indexes {
+		if idx.Name != "PRIMARY" {
+			stmts = append(stmts, d.AddIndexSql(t.Name, idx))
+		}
+	}
+	return strings.Join(stmts, "\n")
+}
+
+// AddColumnSql appends the column since SQLite has no column positions
+func (d sqliteDialect) AddColumnSql(table string, c *SchemaColumn, _ string) string {
+	stmt := "ALTER TABLE " + d.QuoteIdent(table) + " ADD COLUMN " + c.Definition(d) + ";"
+	if !c.Nullable && c.Default == nil {
+		return d.rebuildNote(table, "cannot add NOT NULL column without default: "+stmt)
+	}
+	return stmt
+}
+
+func (d sqliteDialect) ModifyColumnSql(table string, c *SchemaColumn) string {
+	return d.rebuildNote(table, "cannot modify column to "+c.Definition(d))
+}
+
+func (d sqliteDialect) DropColumnSql(table string, column string) string {
+	return "ALTER TABLE " + d.QuoteIdent(table) + " DROP COLUMN " + d.QuoteIdent(column) + ";"
+}
+
+func (d sqliteDialect) AddIndexSql(table string, idx *SchemaIndex) string {
+	if idx.Name == "PRIMARY" {
+		return d.rebuildNote(table, "cannot add "+idx.Definition(d))
+	}
+	unique := ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", unique, d.QuoteIdent(idx.Name), d.QuoteIdent(table), quoteIdents(d, idx.Columns))
+}
+
+func (d sqliteDialect) ReplaceIndexSql(table string, idx *SchemaIndex) string {
+	if idx.Name == "PRIMARY" {
+		return d.rebuildNote(table, "cannot change primary key to "+idx.Definition(d))
+	}
+	return d.DropIndexSql(table, idx) + "\n" + d.AddIndexSql(table, idx)
+}
+
+func (d sqliteDialect) DropIndexSql(table string, idx *SchemaIndex) string {
+	if idx.Name == "PRIMARY" {
+		return d.rebuildNote(table, "cannot drop primary key")
+	}
+	return "DROP INDEX " + d.QuoteIdent(idx.Name) + ";"
+}
+
+func (d sqliteDialect) TableOptionsSql(t *SchemaTable, attributes []string) string {
+	return fmt.Sprintf("-- SQLite has no table options, skipped %s of %s", strings.Join(attributes, ", "), d.QuoteIdent(t.Name))
+}
+
+// rebuildNote tells to rebuild table for the change which ALTER TABLE of SQLite cannot make
+func (d sqliteDialect) rebuildNote(table string, reason string) string {
+	return fmt.Sprintf("-- SQLite %s, rebuild %s: create a new table with the base definition, copy rows, drop the old table and rename the new one",
+		reason, d.QuoteIdent(table))
+}
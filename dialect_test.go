@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDialect(t *testing.T) {
+	as := assert.New(t)
+	mysql, sqlite := dialectOf("mysql"), dialectOf("sqlite3")
+	as.Equal("`order`", mysql.QuoteIdent("order"))
+	as.Equal("`db`.`a``b`", mysql.QuoteIdent("db.a`b"))
+	as.Equal(`"order"`, sqlite.QuoteIdent("order"))
+	as.Equal(`"order"`, sqlite.QuoteIdent(`"order"`))
+	as.Equal("*", sqlite.QuoteIdent("*"))
+	as.Equal(`'it''s \\'`, mysql.Literal(`it's \`))
+	as.Equal(`'it''s \'`, sqlite.Literal(`it's \`))
+	as.Equal("select 1 limit 10 offset 20", sqlite.Paging("select 1", 10, 20))
+	as.Equal("explain query plan select 1", sqlite.Explain("select 1"))
+	stmt, vertical := parseStmt(" EXPLAIN select 1\\G", sqlite)
+	as.Equal("explain query plan select 1", stmt)
+	as.True(vertical)
+	stmt, _ = parseStmt("explain select 1", mysql)
+	as.Equal("explain select 1", stmt)
+	stmt, _ = parseStmt("explain query plan select 1", sqlite)
+	as.Equal("explain query plan select 1", stmt)
+	as.Equal("INSERT INTO `order`(`id`,`desc`) VALUES ('1',null)",
+		generateInsertSql(mysql, "order", []string{"id", "desc"}, []string{"1", "NULL"}))
+	idx := &SchemaIndex{Name: "idx_a", Columns: []string{"a", "b"}}
+	as.Equal("INDEX `idx_a` (`a`,`b`)", idx.Definition(mysql))
+	as.Equal(`INDEX "idx_a" ("a","b")`, idx.Definition(sqlite))
+	as.Panics(func() { dialectOf("oracle") })
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"sqler/pkg"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DictJob writes the data dictionary of all datasources to a markdown or html file, the tables
+// are described by the first datasource which has them and the differences of others are annotated
+type DictJob struct {
+	sqler    *Sqler
+	fileName string
+	*BaseJob
+}
+
+func NewDictJob(sqler *Sqler, fileName string) Job {
+	return &DictJob{
+		sqler:    sqler,
+		fileName: fileName,
+		BaseJob:  NewBaseJob(new(JobCtx)),
+	}
+}
+
+type dictTable struct {
+	Name    string
+	Differ  bool
+	Comment string
+	Base    string
+	Rows    string
+	Notes   []string
+	Columns [][]string
+	Indexes [][]string
+}
+
+type dictData struct {
+	Now           string
+	DataSources   []string
+	ColumnHeader  []string
+	IndexHeader   []string
+	Tables        []*dictTable
+	DiffTableSize int
+}
+
+// Tables are linked by explicit anchors, the * of differing tables would change the anchors of headings
+var dictMarkdownTemplate = template.Must(template.New("dict").Funcs(template.FuncMap{
+	"cell": markdownCell,
+	"join": strings.Join,
+}).Parse(
+	`# Data dictionary
+
+Datasources: {{join .DataSources ", "}}. Generated at {{.Now}}, {{.DiffTableSize}} tables differ between datasources (*).
+
+| Table | Comment |
+| --- | --- |
+{{range .Tables}}| [{{cell .Name}}](#{{.Name}}){{if .Differ}} *{{end}} | {{cell .Comment}} |
+{{end}}{{range .Tables}}
+<a id="{{.Name}}"></a>
+
+## {{.Name}}{{if .Differ}} *{{end}}
+
+{{if .Comment}}{{.Comment}}
+
+{{end}}Base datasource: {{.Base}}. Rows: {{.Rows}}
+
+{{range .Notes}}* {{.}}
+{{end}}{{if .Notes}}
+{{end}}|{{range $.ColumnHeader}} {{.}} |{{end}} Differences |
+|{{range $.ColumnHeader}} --- |{{end}} --- |
+{{range .Columns}}|{{range .}} {{cell .}} |{{end}}
+{{end}}
+|{{range $.IndexHeader}} {{.}} |{{end}} Differences |
+|{{range $.IndexHeader}} --- |{{end}} --- |
+{{range .Indexes}}|{{range .}} {{cell .}} |{{end}}
+{{end}}{{end}}`))
+
+var dictHtmlTemplate = htmltemplate.Must(htmltemplate.New("dict").Funcs(htmltemplate.FuncMap{
+	"marked": func(cell string) bool { return strings.HasSuffix(cell, " *") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Data dictionary</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; margin: 20px; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 3px 6px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+td.diff, li { color: #721c24; }
+</style>
+</head>
+<body>
+<h1>Data dictionary</h1>
+<p>Datasources: {{range $i, $ds := .DataSources}}{{if $i}}, {{end}}{{$ds}}{{end}}. Generated at {{.Now}}, {{.DiffTableSize}} tables differ between datasources (*).</p>
+<table>
+<tr><th>Table</th><th>Comment</th></tr>
+{{range .Tables}}<tr><td><a href="#{{.Name}}">{{.Name}}</a>{{if .Differ}} *{{end}}</td><td>{{.Comment}}</td></tr>
+{{end}}</table>
+{{range .Tables}}<h2 id="{{.Name}}">{{.Name}}{{if .Differ}} *{{end}}</h2>
+{{if .Comment}}<p>{{.Comment}}</p>{{end}}
+<p>Base datasource: {{.Base}}. Rows: {{.Rows}}</p>
+{{if .Notes}}<ul>{{range .Notes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<table>
+<tr>{{range $.ColumnHeader}}<th>{{.}}</th>{{end}}<th>Differences</th></tr>
+{{range .Columns}}<tr>{{range .}}<td{{if marked .}} class="diff"{{end}}>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+<table>
+<tr>{{range $.IndexHeader}}<th>{{.}}</th>{{end}}<th>Differences</th></tr>
+{{range .Indexes}}<tr>{{range .}}<td{{if marked .}} class="diff"{{end}}>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+func (job *DictJob) Exec() {
+	ext := strings.ToLower(filepath.Ext(job.fileName))
+	if ext != ".md" && ext != ".html" {
+		job.RecordError(fmt.Errorf("data dictionary file %s must end with .md or .html", job.fileName))
+		return
+	}
+	// The datasources failed to load are flagged with ERROR and their errors recorded after printing
+	schemas, loadErr := loadAllSchemaTables(job.sqler)
+	if !anySchemaLoaded(schemas) {
+		job.RecordError(loadErr)
+		return
+	}
+	dataSources := job.sqler.cfg.DataSources
+	data := &dictData{
+		Now:          pkg.Now(),
+		DataSources:  dataSourceNames(dataSources),
+		ColumnHeader: descColumnHeader,
+		IndexHeader:  descIndexHeader,
+		Tables:       dictTables(schemas, dataSources),
+	}
+	for _, t := range data.Tables {
+		if t.Differ {
+			data.DiffTableSize++
+		}
+	}
+
+	file, err := os.OpenFile(job.fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
+	if job.RecordError(err) {
+		return
+	}
+	defer file.Close()
+	if ext == ".md" {
+		err = dictMarkdownTemplate.Execute(file, data)
+	} else {
+		err = dictHtmlTemplate.Execute(file, data)
+	}
+	if job.RecordError(err) {
+		return
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Wrote data dictionary of %d tables to %s, %d tables differ between datasources (*)",
+		pkg.Now(), len(data.Tables), job.fileName, data.DiffTableSize))
+	job.RecordError(loadErr)
+}
+
+// dictTables describes the tables of all schemas in name order
+func dictTables(schemas []map[string]*SchemaTable, dataSources []*pkg.DataSourceConfig) []*dictTable {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, tables := range schemas {
+		for name := range tables {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	tables := make([]*dictTable, 0, len(names))
+	for _, name := range names {
+		desc := describeTable(schemas, dataSources, name)
+		rows := make([]string, 0, len(schemas))
+		for dbIdx, schema := range schemas {
+			if t := schema[name]; t != nil {
+				rows = append(rows, dataSources[dbIdx].Name()+" "+strconv.FormatInt(t.RowsEst, 10))
+			} else if schema == nil {
+				rows = append(rows, dataSources[dbIdx].Name()+" "+countError)
+			} else {
+				rows = append(rows, dataSources[dbIdx].Name()+" "+countAbsent)
+			}
+		}
+		tables = append(tables, &dictTable{
+			Name:    name,
+			Differ:  len(desc.notes) > 0 || len(desc.columns.notes) > 0 || len(desc.indexes.notes) > 0,
+			Comment: desc.base.Comment,
+			Base:    dataSources[desc.baseIdx].Name(),
+			Rows:    strings.Join(rows, ", "),
+			Notes:   desc.notes,
+			Columns: desc.columns.cells(),
+			Indexes: desc.indexes.cells(),
+		})
+	}
+	return tables
+}
+
+// markdownCell escapes the pipes and line breaks of value in a markdown table
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(value)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"sqler/pkg"
+	"testing"
+)
+
+func TestDictTables(t *testing.T) {
+	as := assert.New(t)
+	id := &SchemaColumn{Name: "id", Type: "bigint"}
+	schemas := []map[string]*SchemaTable{
+		{"user": {Name: "user", Comment: "用户", RowsEst: 10, Columns: []*SchemaColumn{id}}},
+		{
+			"user": {Name: "user", Comment: "用户", RowsEst: 12, Columns: []*SchemaColumn{id, {Name: "name", Type: "varchar(8)"}}},
+			"role": {Name: "role", Columns: []*SchemaColumn{id}},
+		},
+	}
+	tables := dictTables(schemas, []*pkg.DataSourceConfig{{Alias: "base", Type: "mysql"}, {Alias: "s01", Type: "mysql"}})
+	as.Len(tables, 2)
+
+	as.Equal("role", tables[0].Name)
+	as.Equal("s01", tables[0].Base)
+	as.Equal("base absent, s01 0", tables[0].Rows)
+	as.Equal([]string{"base: absent"}, tables[0].Notes)
+	as.True(tables[0].Differ)
+
+	as.Equal("user", tables[1].Name)
+	as.Equal("用户", tables[1].Comment)
+	as.Equal("base 10, s01 12", tables[1].Rows)
+	as.Equal([][]string{
+		{"id", "bigint", "false", "(none)", "", "", ""},
+		{"name *", "varchar(8)", "false", "(none)", "", "", "s01: extra"},
+	}, tables[1].Columns)
+	as.True(tables[1].Differ)
+
+	as.Equal(`a\|b<br>c`, markdownCell("a|b\nc"))
+}
+
+func TestDictMarkdownAnchor(t *testing.T) {
+	as := assert.New(t)
+	var buf bytes.Buffer
+	err := dictMarkdownTemplate.Execute(&buf, &dictData{Tables: []*dictTable{{Name: "user", Differ: true}}})
+	as.Nil(err)
+	as.Contains(buf.String(), "| [user](#user) * |")
+	as.Contains(buf.String(), "<a id=\"user\"></a>\n\n## user *\n")
+}
+
+func TestDictTablesFailedDataSource(t *testing.T) {
+	as := assert.New(t)
+	schemas := []map[string]*SchemaTable{
+		nil,
+		{"user": {Name: "user", RowsEst: 12, Columns: []*SchemaColumn{{Name: "id", Type: "bigint"}}}},
+	}
+	tables := dictTables(schemas, []*pkg.DataSourceConfig{{Alias: "base", Type: "mysql"}, {Alias: "s01", Type: "mysql"}})
+	as.Len(tables, 1)
+	as.Equal("s01", tables[0].Base)
+	as.Equal("base ERROR, s01 12", tables[0].Rows)
+	as.Equal([]string{"base: ERROR"}, tables[0].Notes)
+	as.True(anySchemaLoaded(schemas))
+	as.False(anySchemaLoaded(make([]map[string]*SchemaTable, 2)))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"sqler/pkg"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DupCheckJob finds the keys of a sharded table which appear on more than one datasource or
+// more than once overall, the key columns of every datasource are scanned by DupScanJob
+type DupCheckJob struct {
+	sqler   *Sqler
+	table   string
+	columns []string
+	*BaseJob
+}
+
+func NewDupCheckJob(sqler *Sqler, table string, columns []string) Job {
+	return &DupCheckJob{
+		sqler:   sqler,
+		table:   table,
+		columns: columns,
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+// dupKeys counts the keys of every datasource, keys[key][dbIdx] is the times key appears at dbIdx
+type dupKeys struct {
+	mu   sync.Mutex
+	keys map[string]map[int]int
+}
+
+func (d *dupKeys) add(key string, dbIdx int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	locations, ok := d.keys[key]
+	if !ok {
+		locations = make(map[int]int, 1)
+		d.keys[key] = locations
+	}
+	locations[dbIdx]++
+}
+
+func (job *DupCheckJob) Exec() {
+	if err := os.Mkdir("dupcheck", 0755); err != nil && !os.IsExist(err) {
+		job.RecordError(err)
+		return
+	}
+	keys := &dupKeys{keys: make(map[string]map[int]int)}
+	dbSize := len(job.sqler.dbs)
+	jobExecutor := NewJobExecutor(dbSize)
+	jobExecutor.Start()
+	jobs := make([]Job, dbSize)
+	for dbIdx := range job.sqler.dbs {
+		jobs[dbIdx] = NewDupScanJob(job.sqler, job.table, job.columns, dbIdx, keys)
+		jobExecutor.Submit(jobs[dbIdx], dbIdx)
+	}
+	jobExecutor.Shutdown(true)
+	failed := 0
+	for _, scanJob := range jobs {
+		if scanJob.Error() != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		job.RecordError(fmt.Errorf("%d/%d dupcheck scans failed", failed, len(jobs)))
+		return
+	}
+
+	// Duplicated keys sorted by key
+	dups := make([]string, 0)
+	for key, locations := range keys.keys {
+		total := 0
+		for _, n := range locations {
+			total += n
+		}
+		if len(locations) > 1 || total > 1 {
+			dups = append(dups, key)
+		}
+	}
+	sort.Strings(dups)
+
+	csvFileName := fmt.Sprintf("dupcheck/%s.csv", job.table)
+	file, err := os.OpenFile(csvFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
+	if job.RecordError(err) {
+		return
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	_ = w.Write(append(append([]string{"Table"}, job.columns...), "Total", "DataSources", "Locations"))
+	for _, key := range dups {
+		locations := keys.keys[key]
+		dbIds := make([]int, 0, len(locations))
+		total := 0
+		for dbIdx, n := range locations {
+			dbIds = append(dbIds, dbIdx)
+			total += n
+		}
+		sort.Ints(dbIds)
+		where := make([]string, len(dbIds))
+		for i, dbIdx := range dbIds {
+			where[i] = fmt.Sprintf("%s:%d", job.sqler.cfg.DataSources[dbIdx].DsKey(), locations[dbIdx])
+		}
+		record := append([]string{job.table}, strings.Split(key, "\x1f")...)
+		_ = w.Write(append(record, strconv.Itoa(total), strconv.Itoa(len(dbIds)), strings.Join(where, " ")))
+	}
+	w.Flush()
+	if job.RecordError(w.Error()) {
+		return
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Checked %d keys (%s) of table %s, %d keys are duplicated, saved to csv file: %s",
+		pkg.Now(), len(keys.keys), strings.Join(job.columns, ","), job.table, len(dups), csvFileName))
+}
+
+// DupScanJob streams the key columns of table at one datasource to dupKeys
+type DupScanJob struct {
+	sqler   *Sqler
+	table   string
+	columns []string
+	dbIdx   int
+	keys    *dupKeys
+	*BaseJob
+}
+
+func NewDupScanJob(sqler *Sqler, table string, columns []string, dbIdx int, keys *dupKeys) Job {
+	return &DupScanJob{
+		sqler:   sqler,
+		table:   table,
+		columns: columns,
+		dbIdx:   dbIdx,
+		keys:    keys,
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *DupScanJob) BeforeExec() {
+	job.PrintBeforeExec(fmt.Sprintf("[%s] Scanning keys of table %s at db %s (%d/%d) ... ", pkg.Now(),
+		job.table, job.dsKey(), job.dbIdx+1, len(job.sqler.dbs)))
+}
+
+func (job *DupScanJob) Exec() {
+	dialect := job.sqler.Dialect(job.dbIdx)
+	query := fmt.Sprintf("select %s from %s", quoteIdents(dialect, job.columns), dialect.QuoteIdent(job.table))
+	rows := 0
+	err := queryEachAsString(job.sqler.Reader(job.dbIdx), query, func(row []string) error {
+		job.keys.add(strings.Join(row, "\x1f"), job.dbIdx)
+		rows++
+		return nil
+	})
+	if err != nil {
+		job.RecordError(fmt.Errorf("failed to scan %s at db %s: %w", job.table, job.dsKey(), err))
+		return
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Scanned %d keys of table %s at db %s (%d/%d)", pkg.Now(),
+		rows, job.table, job.dsKey(), job.dbIdx+1, len(job.sqler.dbs)))
+}
+
+func (job *DupScanJob) dsKey() string {
+	return job.sqler.cfg.DataSources[job.dbIdx].DsKey()
+}
+
+// parseDupCheckArgs parses args like "table col_1 col_2" or "table col_1,col_2"
+func parseDupCheckArgs(args []string) (string, []string, error) {
+	if len(args) < 2 {
+		return "", nil, fmt.Errorf("table and key columns are required")
+	}
+	columns := make([]string, 0)
+	for _, arg := range args[1:] {
+		for _, column := range strings.Split(arg, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				columns = append(columns, column)
+			}
+		}
+	}
+	return args[0], columns, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseDupCheckArgs(t *testing.T) {
+	as := assert.New(t)
+	table, columns, err := parseDupCheckArgs([]string{"orders", "tenant_id,order_no", "region"})
+	as.NoError(err)
+	as.Equal("orders", table)
+	as.Equal([]string{"tenant_id", "order_no", "region"}, columns)
+	_, _, err = parseDupCheckArgs([]string{"orders"})
+	as.Error(err)
+}
@@ -5,14 +5,17 @@ import (
 	"crypto/rand"
 	"encoding/csv"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"sqler/pkg"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/elk-language/go-prompt"
 	"github.com/olekukonko/tablewriter"
@@ -38,6 +41,25 @@ var (
 	flagSchemas      string
 	flagMaxRowNumber int
 	flagBatchRow     int
+	flagBdiffPara    int
+	flagBdiffBase    string
+	flagBdiffMode    string
+	flagSchemaDiff   bool
+	flagSnapshot     string
+	flagBdiffTargets string
+	flagBdiffCsv     string
+	flagBdiffCsvMap  string
+	flagBdiffCsvKeys string
+	flagBdiffResume  bool
+	flagBdiffHtml    bool
+	flagAgainst      string
+	flagAgainstKey   string
+	flagAgainstPair  string
+	flagConsistent   bool
+	flagOrphan       bool
+	flagSearch       string
+	flagDict         string
+	flagMetaTTL      time.Duration
 	flagOutputFile   string
 	flagPara         bool
 )
@@ -58,9 +80,28 @@ func parseFlags() {
 	flag.BoolVar(&flagGenHexAesKey, "gen-key", false, "生成16进制的aes密钥")
 	flag.StringVar(&flagHexAesKey, "key", "", "指定16进制格式的密钥")
 	flag.BoolVar(&flagBdiff, "bdiff", false, "执行数据比对")
+	flag.StringVar(&flagBdiffTargets, "bdiff-targets", "", "参与数据比对的数据源（ds_1 ds_2 ...，默认所有数据源）")
+	flag.StringVar(&flagBdiffCsv, "bdiff-csv", "", "以CSV文件作为基准数据与数据源比对（-schemas指定一个表）")
+	flag.StringVar(&flagBdiffCsvMap, "bdiff-csv-map", "", "CSV表头与列的映射（header_1=col_1,header_2=col_2,忽略的表头=）")
+	flag.StringVar(&flagBdiffCsvKeys, "bdiff-csv-keys", "", "CSV数据的主键列（col_1 col_2 ...，默认使用配置或第一列）")
+	flag.BoolVar(&flagBdiffResume, "bdiff-resume", false, "从上次中断处继续数据比对（跳过bdiff/state.log中已完成的表和分批，分批行数和表的where、列、主键须与上次相同）")
+	flag.BoolVar(&flagBdiffHtml, "bdiff-html", false, "生成并排展示差异的HTML报告（bdiff/report.html）")
+	flag.StringVar(&flagAgainst, "against", "", "与另一个配置文件的数据源进行数据比对（-c为基准）")
+	flag.StringVar(&flagAgainstKey, "against-key", "", "另一个配置文件的16进制aes密钥（默认与-c相同）")
+	flag.StringVar(&flagAgainstPair, "against-pair", BdiffPairPosition, "数据源配对方式（position|alias|left_1=right_1,left_2=right_2）")
+	flag.BoolVar(&flagConsistent, "consistent", false, "在所有数据源上同时开启一致性快照后再读取（作用于数据比对、/count和只含查询语句的导出）")
+	flag.BoolVar(&flagOrphan, "orphan", false, "检查配置中relations的跨分片关联数据（-schemas指定关联名，默认所有关联）")
+	flag.StringVar(&flagSearch, "search", "", "在表名、字段名和表/字段注释中搜索文本")
+	flag.StringVar(&flagSnapshot, "bdiff-snapshot", "", "保存或比对数据快照（save|compare，快照文件为最后一个参数，-bdiff-base指定数据源，默认所有数据源）")
+	flag.BoolVar(&flagSchemaDiff, "schema-diff", false, "执行表结构比对并生成变更脚本（基准数据源由-bdiff-base指定）")
 	flag.StringVar(&flagSchemas, "schemas", "", "数据比对的表 (table_a table_2 ...)")
 	flag.IntVar(&flagMaxRowNumber, "max-row", 100000, "数据比对最大行数")
 	flag.IntVar(&flagBatchRow, "batch-row", 0, "数据比对每批行数（默认0不限制）")
+	flag.IntVar(&flagBdiffPara, "bdiff-para", 4, "数据比对并发数")
+	flag.StringVar(&flagBdiffBase, "bdiff-base", "", "数据比对的基准数据源（别名、url/schema或ID，默认第一个数据源）")
+	flag.StringVar(&flagBdiffMode, "bdiff-mode", BdiffModeBase, "数据比对模式（base: 与基准比对, pairwise: 两两比对, majority: 与多数数据源一致的数据比对）")
+	flag.StringVar(&flagDict, "dict", "", "生成所有数据源的数据字典（out.md或out.html），标记各数据源不一致的地方")
+	flag.DurationVar(&flagMetaTTL, "meta-ttl", 24*time.Hour, "表和字段元数据缓存（meta_cache目录）的有效期，0表示每次启动重新加载")
 	flag.StringVar(&flagOutputFile, "o", "", "结果导出到文件")
 	flag.BoolVar(&flagPara, "p", false, "并发执行模式")
 	flag.Parse()
@@ -91,11 +132,12 @@ func initSqler(override bool) {
 		if err != nil {
 			panic(err)
 		}
+		cfg.FileName = configFile
 		sqler = NewSqler(cfg)
-		if err := sqler.loadSchema(); err != nil {
+		if err := sqler.loadSchema(flagMetaTTL); err != nil {
 			fmt.Println("Failed to load schema: " + err.Error())
 		}
-		initPromptSuggest(sqler.tableMetas, sqler.columnMeats)
+		initPromptSuggest(sqler.tableMetas, sqler.columnMeats, sqler.DataSourceTypes()...)
 		sqlStmtCache = new(strings.Builder)
 	}
 }
@@ -133,6 +175,10 @@ func cli() {
 				printer.Error("Failed to load sql file "+flagSqlFile, err)
 				return
 			}
+			if err := checkConsistentStmts(stmts); err != nil {
+				printer.Error("Failed to export "+flagSqlFile, err)
+				return
+			}
 			csvFile, err := os.OpenFile(flagOutputFile, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
 			if err != nil {
 				panic(err)
@@ -147,7 +193,9 @@ func cli() {
 				CsvFileHeaderWrote: false,
 				CsvFileLock:        &sync.Mutex{},
 			}
-			execSql(jobCtx, stmts...)
+			withConsistentSnapshots(sqler, func() {
+				execSql(jobCtx, stmts...)
+			})
 		}
 		return
 	}
@@ -178,26 +226,111 @@ func cli() {
 		return
 	}
 
+	if flagSnapshot != "" {
+		initComponents()
+		var schemas []string
+		if flagSchemas != "" {
+			schemas = strings.Split(flagSchemas, " ")
+		}
+		execJob(NewBdiffSnapshotJob(sqler, flagSnapshot, flag.Arg(0), schemas, &BdiffOptions{
+			BatchRow: flagBatchRow,
+			Base:     flagBdiffBase,
+		}))
+		return
+	}
+
 	if flagBdiff {
 		initComponents()
+		if err := checkAgainstFlags(); err != nil {
+			printer.Error("Invalid flags", err)
+			return
+		}
 		var schemas []string
 		if flagSchemas == "" {
 			schemas = sqler.cfg.CommandsConfig.BdiffSchemas
 		} else {
 			schemas = strings.Split(flagSchemas, " ")
 		}
-		bdiffJob := NewBdiffJob(sqler, schemas, flagMaxRowNumber, flagBatchRow)
-		jobExecutor := NewJobExecutor(1)
-		jobExecutor.Start()
-		jobExecutor.Submit(bdiffJob, 0)
-		jobExecutor.Shutdown(true)
+		csvMapping, err := parseCsvMapping(flagBdiffCsvMap)
+		if err != nil {
+			printer.Error("Invalid csv mapping", err)
+			return
+		}
+		bdiffSqler := sqler
+		var pairs [][2]int
+		if flagAgainst != "" {
+			var other *Sqler
+			if bdiffSqler, other, pairs, err = loadBdiffAgainst(); err != nil {
+				printer.Error("Failed to load "+flagAgainst, err)
+				return
+			}
+			defer other.Close()
+		}
+		bdiffJob := NewBdiffJob(bdiffSqler, schemas, &BdiffOptions{
+			MaxRow:     flagMaxRowNumber,
+			BatchRow:   flagBatchRow,
+			Parallel:   flagBdiffPara,
+			Base:       flagBdiffBase,
+			Mode:       flagBdiffMode,
+			Targets:    strings.Fields(flagBdiffTargets),
+			CsvBase:    flagBdiffCsv,
+			CsvMapping: csvMapping,
+			CsvKeys:    strings.Fields(flagBdiffCsvKeys),
+			Resume:     flagBdiffResume,
+			Html:       flagBdiffHtml,
+			Pairs:      pairs,
+		})
+		withConsistentSnapshots(bdiffSqler, func() {
+			execJob(bdiffJob)
+		})
 		//printer.WaitForNoJob(true)
 		return
 	}
 
+	if flagOrphan {
+		initComponents()
+		var names []string
+		if flagSchemas != "" {
+			names = strings.Split(flagSchemas, " ")
+		}
+		relations, err := findRelations(sqler.cfg.CommandsConfig, names)
+		if err != nil {
+			printer.Error("Invalid relations", err)
+			return
+		}
+		orphanJob := NewOrphanJob(sqler, relations, flagBatchRow)
+		withConsistentSnapshots(sqler, func() {
+			execJob(orphanJob)
+		})
+		return
+	}
+
+	if flagSearch != "" {
+		initComponents()
+		execJob(NewSearchJob(sqler, flagSearch))
+		return
+	}
+
+	if flagDict != "" {
+		initComponents()
+		execJob(NewDictJob(sqler, flagDict))
+		return
+	}
+
+	if flagSchemaDiff {
+		initComponents()
+		var tables []string
+		if flagSchemas != "" {
+			tables = strings.Split(flagSchemas, " ")
+		}
+		execJob(NewSchemaDiffJob(sqler, flagBdiffBase, tables))
+		return
+	}
+
 	if flagInteractive {
 		doActions = true
 		initComponents()
+		loadPromptRecent()
 		p := prompt.New(
 			executor,
 			prompt.WithCompleter(completer),
@@ -210,6 +343,9 @@ func cli() {
 			}),
 		)
 		p.Run()
+		if err := savePromptRecent(); err != nil {
+			printer.Error("Failed to save "+promptRecentFile, err)
+		}
 	}
 
 	if !doActions {
@@ -217,6 +353,52 @@ func cli() {
 	}
 }
 
+// checkAgainstFlags rejects the flags which choose base and targets with -against, the datasources
+// are paired by -against-pair instead
+func checkAgainstFlags() error {
+	if flagAgainst == "" {
+		return nil
+	}
+	conflicts := make([]string, 0)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "bdiff-base" || f.Name == "bdiff-targets" || f.Name == "bdiff-mode" {
+			conflicts = append(conflicts, "-"+f.Name)
+		}
+	})
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%s can not be used with -against, use -against-pair instead", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// loadBdiffAgainst connects to the datasources of -against config and pairs them with the current ones,
+// the sqler of -against config is returned to be closed when done
+func loadBdiffAgainst() (*Sqler, *Sqler, [][2]int, error) {
+	aesKey := loadAesKey()
+	if flagAgainstKey != "" {
+		var err error
+		if aesKey, err = hex.DecodeString(flagAgainstKey); err != nil {
+			return nil, nil, nil, err
+		}
+		if len(aesKey) != 16 {
+			return nil, nil, nil, errors.New("not valid aes key: " + flagAgainstKey)
+		}
+	}
+	otherCfg, err := pkg.LoadConfigFromFile(flagAgainst, pkg.NewAes(aesKey, pkg.DefaultIV))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pairs, err := bdiffAgainstPairs(sqler.cfg, otherCfg, flagAgainstPair)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	againstSqler, other, err := NewBdiffAgainstSqler(sqler, otherCfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return againstSqler, other, pairs, nil
+}
+
 func loadAesKey() []byte {
 	hexAesKey := ""
 	if flagHexAesKey != "" {
@@ -253,6 +435,7 @@ func executor(line string) {
 	if line == "" {
 		return
 	}
+	recordRecent(line)
 
 	if strings.HasPrefix(line, pkg.CmdSource) {
 		files := strings.Split(line, " ")[1:]
@@ -263,9 +446,9 @@ func executor(line string) {
 	if strings.HasPrefix(line, pkg.CmdDatasource) {
 		b := new(bytes.Buffer)
 		table := tablewriter.NewWriter(b)
-		table.SetHeader([]string{"ID", "URL", "Schema", "Enabled"})
+		table.SetHeader([]string{"ID", "Alias", "URL", "Schema", "Enabled"})
 		for i, ds := range sqler.cfg.DataSources {
-			table.Append([]string{strconv.Itoa(i),
+			table.Append([]string{strconv.Itoa(i), ds.Alias,
 				ds.Url, ds.Schema, strconv.FormatBool(ds.Enabled)})
 		}
 		table.Render()
@@ -300,15 +483,79 @@ func executor(line string) {
 			printer.Info("File name must end with .csv")
 			return
 		}
-		schemes := parts[1:]
-		if len(schemes) == 0 {
-			parts = sqler.cfg.CommandsConfig.CountSchemas
+		// Count-schemas of config or all tables are counted if no table is given
+		schemes, where := parseCountArgs(parts[1:])
+		countJob := NewCountJob(sqler, csvFileName, schemes, where)
+		withConsistentSnapshots(sqler, func() {
+			execJob(countJob)
+		})
+		return
+	}
+
+	if strings.HasPrefix(line, pkg.CmdSchemaDiff) {
+		args := strings.Fields(line)[1:]
+		base := ""
+		if len(args) > 0 {
+			if _, err := sqler.cfg.DataSourceIndex(args[0]); err == nil {
+				base, args = args[0], args[1:]
+			}
+		}
+		execJob(NewSchemaDiffJob(sqler, base, args))
+		return
+	}
+
+	if strings.HasPrefix(line, pkg.CmdDupCheck) {
+		table, columns, err := parseDupCheckArgs(strings.Fields(line)[1:])
+		if err != nil {
+			printer.Error("Invalid args", err)
+			return
+		}
+		dupCheckJob := NewDupCheckJob(sqler, table, columns)
+		withConsistentSnapshots(sqler, func() {
+			execJob(dupCheckJob)
+		})
+		return
+	}
+
+	if strings.HasPrefix(line, pkg.CmdOrphan) {
+		relations, err := findRelations(sqler.cfg.CommandsConfig, strings.Fields(line)[1:])
+		if err != nil {
+			printer.Error("Invalid relations", err)
+			return
+		}
+		orphanJob := NewOrphanJob(sqler, relations, 0)
+		withConsistentSnapshots(sqler, func() {
+			execJob(orphanJob)
+		})
+		return
+	}
+
+	if strings.HasPrefix(line, pkg.CmdTables) {
+		pattern := ""
+		if args := strings.Fields(line)[1:]; len(args) > 0 {
+			pattern = args[0]
 		}
-		countJob := NewCountJob(sqler, csvFileName, schemes)
-		jobExecutor := NewJobExecutor(1)
-		jobExecutor.Start()
-		jobExecutor.Submit(countJob, 0)
-		jobExecutor.Shutdown(true)
+		execJob(NewTablesJob(sqler, pattern))
+		return
+	}
+
+	if strings.HasPrefix(line, pkg.CmdDesc) {
+		args := strings.Fields(line)[1:]
+		if len(args) != 1 {
+			printer.Info("Please provide one table name")
+			return
+		}
+		execJob(NewDescJob(sqler, args[0]))
+		return
+	}
+
+	if strings.HasPrefix(line, pkg.CmdSearch) {
+		text := strings.TrimSpace(strings.TrimPrefix(line, pkg.CmdSearch))
+		if text == "" {
+			printer.Info("Please provide text to search")
+			return
+		}
+		execJob(NewSearchJob(sqler, text))
 		return
 	}
 
@@ -323,17 +570,11 @@ func executor(line string) {
 			printer.Info("File name must end with csv")
 			return
 		}
-		csvFile, err := os.OpenFile(csvFileName, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
-		if err != nil {
-			printer.Error("Failed to create or open file "+csvFileName, err)
-			return
-		}
-		defer csvFile.Close()
 		var stmts []string
 		sqlFileName := parts[2]
 		if strings.HasSuffix(sqlFileName, ".sql") {
-			stmts, err = LoadSqlFile(sqlFileName)
-			if err != nil {
+			var err error
+			if stmts, err = LoadSqlFile(sqlFileName); err != nil {
 				printer.Error("Failed to load sql "+sqlFileName, err)
 				return
 			}
@@ -341,6 +582,16 @@ func executor(line string) {
 			stmt, _ := strings.CutSuffix(sqlFileName, ";")
 			stmts = []string{stmt}
 		}
+		if err := checkConsistentStmts(stmts); err != nil {
+			printer.Error("Failed to export "+sqlFileName, err)
+			return
+		}
+		csvFile, err := os.OpenFile(csvFileName, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+		if err != nil {
+			printer.Error("Failed to create or open file "+csvFileName, err)
+			return
+		}
+		defer csvFile.Close()
 		sqlJobCtx := &JobCtx{
 			StopWhenError:      false,
 			Serial:             false,
@@ -350,7 +601,16 @@ func executor(line string) {
 			CsvFileHeaderWrote: false,
 			CsvFileLock:        &sync.Mutex{},
 		}
-		execSql(sqlJobCtx, stmts...)
+		withConsistentSnapshots(sqler, func() {
+			execSql(sqlJobCtx, stmts...)
+		})
+		return
+	}
+
+	if strings.HasPrefix(line, pkg.CmdRefresh) {
+		refreshSchema()
+		printer.Info(fmt.Sprintf("[%s] Refreshed metas of %d tables and %d columns",
+			pkg.Now(), len(sqler.tableMetas), len(sqler.columnMeats)))
 		return
 	}
 
@@ -388,12 +648,67 @@ func sourceSqlFiles(files []string) {
 	}
 }
 
+// withConsistentSnapshots runs fn with the reads from consistent snapshots if -consistent
+func withConsistentSnapshots(s *Sqler, fn func()) {
+	if !flagConsistent {
+		fn()
+		return
+	}
+	if err := s.OpenSnapshots(); err != nil {
+		printer.Error("Failed to open consistent snapshots", err)
+		return
+	}
+	for dbIdx, ds := range s.cfg.DataSources {
+		printer.Info(fmt.Sprintf("[%s] Opened consistent snapshot of %s", s.SnapshotAt(dbIdx), ds.DsKey()))
+	}
+	defer func() {
+		if err := s.CloseSnapshots(); err != nil {
+			printer.Error("Failed to close consistent snapshots", err)
+		}
+	}()
+	fn()
+}
+
+// checkConsistentStmts rejects the statements which are not reads if -consistent, as the snapshots
+// are rolled back or read only and the writes on them are lost or fail
+func checkConsistentStmts(stmts []string) error {
+	if !flagConsistent {
+		return nil
+	}
+	for _, stmt := range stmts {
+		if !isReadStmt(stmt) {
+			return fmt.Errorf("only queries can be exported from consistent snapshots: %s", stmt)
+		}
+	}
+	return nil
+}
+
+// execJob executes job in a new executor and waits for it done
+func execJob(job Job) {
+	jobExecutor := NewJobExecutor(1)
+	jobExecutor.Start()
+	jobExecutor.Submit(job, 0)
+	jobExecutor.Shutdown(true)
+}
+
 func execSql(jobCtx *JobCtx, sqlStmt ...string) {
 	if jobCtx.Serial {
 		sqler.ExecSerial(jobCtx, sqlStmt...)
 	} else {
 		sqler.ExecPara(jobCtx, sqlStmt...)
 	}
+	// The metas are stale after DDL
+	if slices.ContainsFunc(sqlStmt, isDdlStmt) {
+		refreshSchema()
+	}
+}
+
+// refreshSchema reloads the metas of all datasources and the suggestions of them
+func refreshSchema() {
+	if err := sqler.loadSchema(0); err != nil {
+		printer.Error("Failed to refresh schema", err)
+	}
+	initPromptSuggest(sqler.tableMetas, sqler.columnMeats, sqler.DataSourceTypes()...)
 }
 
 func splitBySpacesWithQuotes(input string) []string {
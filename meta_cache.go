@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const metaCacheDir = "meta_cache"
+
+// metaCache is the table and column metas of a datasource saved on disk
+type metaCache struct {
+	DsKey    string        `json:"dsKey"`
+	LoadedAt time.Time     `json:"loadedAt"`
+	Tables   []*TableMeta  `json:"tables"`
+	Columns  []*ColumnMeta `json:"columns"`
+}
+
+// metaCacheFileName returns the cache file of datasource in config
+func metaCacheFileName(configFile string, dsKey string) string {
+	return filepath.Join(metaCacheDir, fileNameOf(configFile+"."+dsKey)+".json")
+}
+
+// readMetaCache returns the cache of file if it is loaded within ttl
+func readMetaCache(fileName string, ttl time.Duration) (*metaCache, bool) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, false
+	}
+	cache := new(metaCache)
+	if err := json.Unmarshal(data, cache); err != nil || time.Since(cache.LoadedAt) > ttl {
+		return nil, false
+	}
+	return cache, true
+}
+
+func writeMetaCache(fileName string, cache *metaCache) error {
+	if err := os.Mkdir(filepath.Dir(fileName), 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// ddlKeywords start the statements which change the metas of tables
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "COMMENT"}
+
+// isDdlStmt returns true if the first keyword of stmt, after comments, is one of ddlKeywords
+func isDdlStmt(stmt string) bool {
+	keyword := firstKeyword(stmt)
+	return slices.ContainsFunc(ddlKeywords, func(ddlKeyword string) bool {
+		return strings.EqualFold(keyword, ddlKeyword)
+	})
+}
+
+// firstKeyword returns the first word of stmt after comments
+func firstKeyword(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case strings.HasPrefix(stmt, "--") || strings.HasPrefix(stmt, "#"):
+			end := strings.IndexByte(stmt, '\n')
+			if end < 0 {
+				return ""
+			}
+			stmt = stmt[end+1:]
+			continue
+		case strings.HasPrefix(stmt, "/*"):
+			end := strings.Index(stmt, "*/")
+			if end < 0 {
+				return ""
+			}
+			stmt = stmt[end+2:]
+			continue
+		}
+		i := 0
+		for i < len(stmt) && isIdentByte(stmt[i]) {
+			i++
+		}
+		return stmt[:i]
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetaCache(t *testing.T) {
+	as := assert.New(t)
+	fileName := filepath.Join(t.TempDir(), "cache", "db.json")
+	cache := &metaCache{
+		DsKey:    "/db",
+		LoadedAt: time.Now().Add(-time.Hour),
+		Tables:   []*TableMeta{{Name: "a", Comment: "表a"}},
+		Columns:  []*ColumnMeta{{Table: "a", Name: "id", Type: "int"}},
+	}
+	as.NoError(writeMetaCache(fileName, cache))
+	read, ok := readMetaCache(fileName, 2*time.Hour)
+	as.True(ok)
+	as.Equal(cache.Tables, read.Tables)
+	as.Equal(cache.Columns, read.Columns)
+	_, ok = readMetaCache(fileName, time.Minute)
+	as.False(ok)
+}
+
+func TestIsDdlStmt(t *testing.T) {
+	as := assert.New(t)
+	as.True(isDdlStmt("create table a (id int)"))
+	as.True(isDdlStmt("  -- add column\n/* v2 */ ALTER TABLE a add b int"))
+	as.True(isDdlStmt("drop index i on a"))
+	as.False(isDdlStmt("select * from created"))
+	as.False(isDdlStmt("insert into a values (1)"))
+	as.False(isDdlStmt("-- create table a"))
+}
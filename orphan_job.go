@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"sqler/pkg"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// orphanSampleSize is the number of orphan keys shown in the summary
+const orphanSampleSize = 5
+
+// OrphanJob checks the relations of config, the child rows whose parent does not exist are
+// reported per datasource. Child tables are scanned by OrphanScanJob in chunks like bdiff
+type OrphanJob struct {
+	sqler     *Sqler
+	relations []*pkg.RelationConfig
+	batchRow  int
+	*BaseJob
+}
+
+func NewOrphanJob(sqler *Sqler, relations []*pkg.RelationConfig, batchRow int) Job {
+	return &OrphanJob{
+		sqler:     sqler,
+		relations: relations,
+		batchRow:  batchRow,
+		BaseJob:   NewBaseJob(new(JobCtx)),
+	}
+}
+
+// orphanParent is the keys of parent table at a datasource, loaded once and shared by the
+// scan jobs of all shards if the parent table is on another datasource
+type orphanParent struct {
+	sqler    *Sqler
+	relation *pkg.RelationConfig
+	dbIdx    int
+	batchRow int
+	once     sync.Once
+	keys     map[string]bool
+	err      error
+}
+
+func (p *orphanParent) load() error {
+	p.once.Do(func() {
+		p.keys = make(map[string]bool)
+		dialect := p.sqler.Dialect(p.dbIdx)
+		columns := quoteIdents(dialect, p.relation.ParentColumns)
+		query := fmt.Sprintf("select %s from %s", columns, dialect.QuoteIdent(p.relation.Parent))
+		p.err = queryInChunks(p.sqler.Reader(p.dbIdx), dialect, query, columns, p.batchRow, func(_ []string, _ []string, rows [][]string) error {
+			for _, row := range rows {
+				p.keys[strings.Join(row, "\x1f")] = true
+			}
+			return nil
+		})
+	})
+	return p.err
+}
+
+// orphanResult is the orphans of relation at a datasource
+type orphanResult struct {
+	relation   *pkg.RelationConfig
+	dbIdx      int
+	childRows  int
+	orphanRows int
+	// orphanKeys are in the order they are found, counts are the child rows of keys
+	orphanKeys []string
+	counts     map[string]int
+}
+
+func (job *OrphanJob) Exec() {
+	if len(job.relations) == 0 {
+		job.RecordError(fmt.Errorf("no relation is configured"))
+		return
+	}
+	if err := os.Mkdir("orphan", 0755); err != nil && !os.IsExist(err) {
+		job.RecordError(err)
+		return
+	}
+	dbSize := len(job.sqler.dbs)
+	jobExecutor := NewJobExecutorWithCache(dbSize, len(job.relations))
+	jobExecutor.Start()
+	jobs := make([]Job, 0, len(job.relations)*dbSize)
+	results := make([]*orphanResult, 0, len(job.relations)*dbSize)
+	for _, relation := range job.relations {
+		if len(relation.ChildColumns) == 0 || len(relation.ChildColumns) != len(relation.ParentColumns) {
+			job.RecordError(fmt.Errorf("relation %s must have the same number of child and parent columns", relation.RelationName()))
+			break
+		}
+		var sharedParent *orphanParent
+		if relation.ParentDataSource != "" {
+			parentIdx, err := job.sqler.cfg.DataSourceIndex(relation.ParentDataSource)
+			if job.RecordError(err) {
+				break
+			}
+			sharedParent = &orphanParent{sqler: job.sqler, relation: relation, dbIdx: parentIdx, batchRow: job.batchRow}
+		}
+		for dbIdx := range job.sqler.dbs {
+			parent := sharedParent
+			if parent == nil {
+				parent = &orphanParent{sqler: job.sqler, relation: relation, dbIdx: dbIdx, batchRow: job.batchRow}
+			}
+			result := &orphanResult{relation: relation, dbIdx: dbIdx, counts: make(map[string]int)}
+			results = append(results, result)
+			scanJob := NewOrphanScanJob(job.sqler, parent, result, job.batchRow)
+			jobs = append(jobs, scanJob)
+			jobExecutor.Submit(scanJob, dbIdx)
+		}
+	}
+	jobExecutor.Shutdown(true)
+	if job.Error() != nil {
+		return
+	}
+
+	b := new(bytes.Buffer)
+	summary := tablewriter.NewWriter(b)
+	summary.SetHeader([]string{"Relation", "DataSource", "ChildRows", "OrphanRows", "OrphanKeys", "Samples"})
+	summary.SetAutoFormatHeaders(false)
+	for i, relation := range job.relations {
+		csvFileName := fmt.Sprintf("orphan/%s.csv", fileNameOf(relation.RelationName()))
+		relationResults := results[i*dbSize : (i+1)*dbSize]
+		if job.RecordError(writeOrphanCsv(job.sqler, csvFileName, relationResults)) {
+			return
+		}
+		for _, r := range relationResults {
+			samples := make([]string, 0, orphanSampleSize)
+			for _, key := range r.orphanKeys[:min(len(r.orphanKeys), orphanSampleSize)] {
+				samples = append(samples, "("+strings.ReplaceAll(key, "\x1f", ",")+")")
+			}
+			summary.Append([]string{relation.RelationName(), job.sqler.cfg.DataSources[r.dbIdx].DsKey(),
+				strconv.Itoa(r.childRows), strconv.Itoa(r.orphanRows), strconv.Itoa(len(r.orphanKeys)), strings.Join(samples, " ")})
+		}
+	}
+	summary.Render()
+	job.PrintAfterDone(b.String())
+
+	failed := 0
+	for _, scanJob := range jobs {
+		if scanJob.Error() != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		job.RecordError(fmt.Errorf("%d/%d orphan scans failed", failed, len(jobs)))
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Checked %d relations, orphans are saved to orphan/", pkg.Now(), len(job.relations)))
+}
+
+// findRelations returns the relations of names, all relations if names is empty
+func findRelations(cmdCfg *pkg.CommandsConfig, names []string) ([]*pkg.RelationConfig, error) {
+	if len(names) == 0 {
+		return cmdCfg.Relations, nil
+	}
+	relations := make([]*pkg.RelationConfig, 0, len(names))
+	for _, name := range names {
+		relation := cmdCfg.Relation(name)
+		if relation == nil {
+			return nil, fmt.Errorf("relation %s not found", name)
+		}
+		relations = append(relations, relation)
+	}
+	return relations, nil
+}
+
+func writeOrphanCsv(sqler *Sqler, csvFileName string, results []*orphanResult) error {
+	file, err := os.OpenFile(csvFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	relation := results[0].relation
+	_ = w.Write(append(append([]string{"DataSource", "Child"}, relation.ChildColumns...), "Rows", "Parent"))
+	for _, r := range results {
+		dsKey := sqler.cfg.DataSources[r.dbIdx].DsKey()
+		for _, key := range r.orphanKeys {
+			record := append([]string{dsKey, relation.Child}, strings.Split(key, "\x1f")...)
+			_ = w.Write(append(record, strconv.Itoa(r.counts[key]), relation.Parent))
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// OrphanScanJob finds the child rows of relation at one datasource whose parent does not exist
+type OrphanScanJob struct {
+	sqler    *Sqler
+	parent   *orphanParent
+	result   *orphanResult
+	batchRow int
+	*BaseJob
+}
+
+func NewOrphanScanJob(sqler *Sqler, parent *orphanParent, result *orphanResult, batchRow int) Job {
+	return &OrphanScanJob{
+		sqler:    sqler,
+		parent:   parent,
+		result:   result,
+		batchRow: batchRow,
+		BaseJob:  NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *OrphanScanJob) BeforeExec() {
+	job.PrintBeforeExec(fmt.Sprintf("[%s] Checking relation %s at db %s (%d/%d) ... ", pkg.Now(),
+		job.result.relation.RelationName(), job.dsKey(), job.result.dbIdx+1, len(job.sqler.dbs)))
+}
+
+func (job *OrphanScanJob) Exec() {
+	relation, result := job.result.relation, job.result
+	if err := job.parent.load(); err != nil {
+		job.RecordError(fmt.Errorf("failed to load parent %s at db %s: %w", relation.Parent,
+			job.sqler.cfg.DataSources[job.parent.dbIdx].DsKey(), err))
+		return
+	}
+	dialect := job.sqler.Dialect(result.dbIdx)
+	columns := quoteIdents(dialect, relation.ChildColumns)
+	query := fmt.Sprintf("select %s from %s", columns, dialect.QuoteIdent(relation.Child))
+	err := queryInChunks(job.sqler.Reader(result.dbIdx), dialect, query, columns, job.batchRow, func(_ []string, _ []string, rows [][]string) error {
+		for _, row := range rows {
+			result.childRows++
+			// Null references nothing
+			if slices.Contains(row, "NULL") {
+				continue
+			}
+			key := strings.Join(row, "\x1f")
+			if job.parent.keys[key] {
+				continue
+			}
+			if result.counts[key] == 0 {
+				result.orphanKeys = append(result.orphanKeys, key)
+			}
+			result.counts[key]++
+			result.orphanRows++
+		}
+		return nil
+	})
+	if err != nil {
+		job.RecordError(fmt.Errorf("failed to scan child %s at db %s: %w", relation.Child, job.dsKey(), err))
+		return
+	}
+	job.PrintAfterDone(fmt.Sprintf("[%s] Checked relation %s at db %s (%d/%d), %d orphan rows", pkg.Now(),
+		relation.RelationName(), job.dsKey(), result.dbIdx+1, len(job.sqler.dbs), result.orphanRows))
+}
+
+func (job *OrphanScanJob) dsKey() string {
+	return job.sqler.cfg.DataSources[job.result.dbIdx].DsKey()
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sqler/pkg"
+	"testing"
+)
+
+func TestFindRelations(t *testing.T) {
+	as := assert.New(t)
+	cmdCfg := &pkg.CommandsConfig{Relations: []*pkg.RelationConfig{
+		{Name: "order_user", Child: "orders", Parent: "users"},
+		{Child: "items", Parent: "orders"},
+	}}
+	relations, err := findRelations(cmdCfg, nil)
+	as.NoError(err)
+	as.Len(relations, 2)
+	relations, err = findRelations(cmdCfg, []string{"items->orders"})
+	as.NoError(err)
+	as.Equal(cmdCfg.Relations[1:], relations)
+	_, err = findRelations(cmdCfg, []string{"orders"})
+	as.Error(err)
+}
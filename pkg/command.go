@@ -8,6 +8,13 @@ const (
 	CmdCount      = "/count"
 	CmdExportCsv  = "/export-csv"
 	CmdLog        = "/log"
+	CmdSchemaDiff = "/schema-diff"
+	CmdDupCheck   = "/dupcheck"
+	CmdOrphan     = "/orphan"
+	CmdTables     = "/tables"
+	CmdDesc       = "/desc"
+	CmdSearch     = "/search"
+	CmdRefresh    = "/refresh"
 )
 
 func CommandSuggests() [][]string {
@@ -16,8 +23,15 @@ func CommandSuggests() [][]string {
 		{CmdSource, "执行SQL文件（foo.sql）"},
 		{CmdClear, "清除当前输入的部分SQL"},
 		{CmdActive, "激活其他配置文件（当前版本不可用）"},
-		{CmdCount, "查询表中数据行数，不指定参数则从配置中读取（result.csv table_1 table_2 ... ）"},
+		{CmdCount, "并发查询各数据源表中数据行数并标记与第一个数据源不一致的结果，不指定表则从配置或库中读取（result.csv [table_1 table_2 ...] [where 条件]）"},
 		{CmdExportCsv, "导出SQL执行结果到CSV文件 (foo.csv \"select 1 from dual\" 或 foo.csv file.sql)"},
 		{CmdLog, "显示当前日志路径"},
+		{CmdSchemaDiff, "比对各数据源与基准数据源的表结构并生成变更脚本（[base] [table_1 table_2 ...]）"},
+		{CmdDupCheck, "检查分片表的业务主键在所有数据源中是否重复（table col_1 col_2 ...）"},
+		{CmdOrphan, "检查配置的表关联关系，找出父表中不存在的子表数据（[relation_1 relation_2 ...]）"},
+		{CmdTables, "列出各数据源的表、注释、估算行数和大小，标记缺失或不一致的数据源（[pattern]，支持*通配符）"},
+		{CmdDesc, "显示表的字段、索引和DDL，标记与其他数据源不一致的地方（table）"},
+		{CmdSearch, "在表名、字段名和表/字段注释中搜索文本，列出匹配的表和字段（text）"},
+		{CmdRefresh, "重新加载所有数据源的表和字段元数据并更新缓存（执行DDL后自动刷新）"},
 	}
 }
@@ -1,8 +1,10 @@
 package pkg
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -42,6 +44,19 @@ func (cfg *Config) AddDataSource(ds *DataSourceConfig) {
 	cfg.DataSources = append(cfg.DataSources, ds)
 }
 
+// DataSourceIndex finds the datasource by alias, DsKey or index
+func (cfg *Config) DataSourceIndex(name string) (int, error) {
+	for i, ds := range cfg.DataSources {
+		if ds.Alias == name || ds.DsKey() == name {
+			return i, nil
+		}
+	}
+	if idx, err := strconv.Atoi(name); err == nil && idx >= 0 && idx < len(cfg.DataSources) {
+		return idx, nil
+	}
+	return -1, fmt.Errorf("datasource %s not found", name)
+}
+
 func (cfg *Config) decryptProperties(aes *AesCipher) {
 	prefix := "ENC("
 	suffix := ")"
@@ -56,6 +71,7 @@ func (cfg *Config) decryptProperties(aes *AesCipher) {
 }
 
 type DataSourceConfig struct {
+	Alias    string `yaml:"alias,omitempty"`
 	Type     string `yaml:"type"`
 	Url      string `yaml:"url"`
 	Schema   string `yaml:"schema"`
@@ -68,10 +84,84 @@ func (ds *DataSourceConfig) DsKey() string {
 	return ds.Url + "/" + ds.Schema
 }
 
+// Name returns the alias of datasource, or DsKey if no alias
+func (ds *DataSourceConfig) Name() string {
+	if ds.Alias != "" {
+		return ds.Alias
+	}
+	return ds.DsKey()
+}
+
 type CommandsConfig struct {
-	CountSchemas  []string `yaml:"count-schemas"`
-	BdiffSchemas  []string `yaml:"bdiff-schemas"`
-	BdiffSkipCols []string `yaml:"bdiff-skip-cols"`
+	CountSchemas     []string                     `yaml:"count-schemas"`
+	BdiffSchemas     []string                     `yaml:"bdiff-schemas"`
+	BdiffSkipCols    []string                     `yaml:"bdiff-skip-cols"`
+	BdiffTables      map[string]*BdiffTableConfig `yaml:"bdiff-tables"`
+	BdiffNormalizers []*BdiffNormalizerConfig     `yaml:"bdiff-normalizers"`
+	Relations        []*RelationConfig            `yaml:"relations"`
+}
+
+// BdiffTableConfig overrides bdiff behaviour for a single table
+type BdiffTableConfig struct {
+	// Where is appended to the select as a filter, e.g. "updated_at > now() - interval 1 day"
+	Where string `yaml:"where"`
+	// Columns selects only these columns instead of '*'
+	Columns []string `yaml:"columns"`
+	// SkipCols are ignored when comparing, in addition to the global bdiff-skip-cols
+	SkipCols []string `yaml:"skip-cols"`
+	// Keys identify a row, the first column is used if empty
+	Keys []string `yaml:"keys"`
+}
+
+// BdiffNormalizerConfig normalizes values of matched columns before bdiff compares them
+type BdiffNormalizerConfig struct {
+	// Tables limits the normalizer to these tables, empty means all tables
+	Tables []string `yaml:"tables"`
+	// Columns matches columns by name
+	Columns []string `yaml:"columns"`
+	// Types matches columns by database type name, e.g. DECIMAL, DATETIME
+	Types []string `yaml:"types"`
+	// Rules are applied in order: trim, lower, numeric[:tolerance], datetime[:d|h|m|s|ms|us], tz:<zone>, json
+	Rules []string `yaml:"rules"`
+}
+
+// RelationConfig declares that the child columns reference the parent columns, which can not be
+// enforced by foreign keys across shards
+type RelationConfig struct {
+	// Name identifies the relation, "child->parent" is used if empty
+	Name          string   `yaml:"name"`
+	Child         string   `yaml:"child"`
+	ChildColumns  []string `yaml:"child-columns"`
+	Parent        string   `yaml:"parent"`
+	ParentColumns []string `yaml:"parent-columns"`
+	// ParentDataSource is the datasource (alias, url/schema or ID) which has all parent rows,
+	// empty means the parent table is on the same shard as child rows, e.g. a broadcast table
+	ParentDataSource string `yaml:"parent-datasource"`
+}
+
+func (r *RelationConfig) RelationName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Child + "->" + r.Parent
+}
+
+// Relation finds the relation by name, nil if not found
+func (c *CommandsConfig) Relation(name string) *RelationConfig {
+	for _, r := range c.Relations {
+		if r.RelationName() == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// BdiffTable returns the bdiff config of table, never nil
+func (c *CommandsConfig) BdiffTable(table string) *BdiffTableConfig {
+	if tc, ok := c.BdiffTables[table]; ok && tc != nil {
+		return tc
+	}
+	return &BdiffTableConfig{}
 }
 
 func (c *CommandsConfig) AddCountSchema(schema string) {
@@ -3,64 +3,98 @@ package main
 import (
 	"bufio"
 	"github.com/elk-language/go-prompt"
-	"github.com/elk-language/go-prompt/strings"
+	istrings "github.com/elk-language/go-prompt/strings"
 	"os"
-	"path/filepath"
 	"sqler/pkg"
+	"strings"
 )
 
 var (
 	promptSuggest []prompt.Suggest
+	// promptTables are the tables completed in sql context, keyed by lower case names
+	promptTables          map[string]*promptTable
+	promptTableSuggests   []prompt.Suggest
+	promptColumnSuggests  []prompt.Suggest
+	promptCommandSuggests []prompt.Suggest
+	promptDict            *sqlDict
 )
 
-func completer(doc prompt.Document) (suggestions []prompt.Suggest, startChar, endChar strings.RuneNumber) {
-	suggestions = prompt.FilterHasPrefix(promptSuggest, doc.GetWordBeforeCursor(), true)
-	return
+// promptTable is the column suggestions of a table
+type promptTable struct {
+	name    string
+	columns []prompt.Suggest
+}
+
+// completer completes commands by the arguments of them and sql by the clause the cursor is in,
+// the statement includes the lines cached before the current line. Suggestions are ranked by rankSuggests
+func completer(doc prompt.Document) (suggestions []prompt.Suggest, startChar, endChar istrings.RuneNumber) {
+	before := doc.TextBeforeCursor()
+	endChar = doc.CurrentRuneIndex()
+	if sqlStmtCache.Len() == 0 && strings.HasPrefix(strings.TrimSpace(before), "/") {
+		suggests, word := cmdSuggests(strings.TrimLeft(before, " "))
+		return rankSuggests(suggests, word), endChar - istrings.RuneCountInString(word), endChar
+	}
+	cached := ""
+	if sqlStmtCache.Len() > 0 {
+		cached = sqlStmtCache.String() + " "
+	}
+	suggests, word := sqlSuggests(cached+before, cached+doc.Text)
+	return rankSuggests(suggests, word), endChar - istrings.RuneCountInString(word), endChar
 }
 
 //func completer(d prompt2.Document) []prompt.Suggest {
 //	return prompt.FilterHasPrefix(promptSuggest, d.GetWordBeforeCursor(), true)
 //}
 
-func initPromptSuggest(tms []*TableMeta, cms []*ColumnMeta) {
+// initPromptSuggest builds the suggestions of metas, commands and the sql dictionary of dsTypes
+func initPromptSuggest(tms []*TableMeta, cms []*ColumnMeta, dsTypes ...string) {
 	commands := cliCommandSuggests()
-	sqlKeywords := sqlKeyWords()
 	customSuggests := loadCustomSuggests("prompt.txt")
+	promptDict = loadSqlDict(dsTypes...)
 
-	suggestSize := len(tms) + len(cms) + len(commands) + len(sqlKeywords) + len(customSuggests) + 8
+	suggestSize := len(tms) + len(cms) + len(commands) + len(customSuggests) + 8
 	promptSuggest = make([]prompt.Suggest, 0, suggestSize)
 
 	// Table meta
+	promptTables = make(map[string]*promptTable, len(tms))
+	promptTableSuggests = make([]prompt.Suggest, 0, len(tms))
 	for _, tm := range tms {
-		promptSuggest = append(promptSuggest, prompt.Suggest{
+		suggest := prompt.Suggest{
 			Text:        tm.Name,
 			Description: tm.Comment + "[table]",
-		})
+		}
+		promptTables[strings.ToLower(tm.Name)] = &promptTable{name: tm.Name}
+		promptTableSuggests = append(promptTableSuggests, suggest)
+		promptSuggest = append(promptSuggest, suggest)
 	}
 
-	// Column meta
+	// Column meta, the columns of the same name and type are suggested once out of table context
+	promptColumnSuggests = make([]prompt.Suggest, 0, len(cms))
+	seenColumns := make(map[string]bool, len(cms))
 	for _, cm := range cms {
-		promptSuggest = append(promptSuggest, prompt.Suggest{
+		suggest := prompt.Suggest{
 			Text:        cm.Name,
-			Description: cm.Comment + "[" + cm.Type + "]",
-		})
+			Description: strings.TrimSpace(cm.Type + " " + cm.Comment),
+		}
+		if t, ok := promptTables[strings.ToLower(cm.Table)]; ok {
+			t.columns = append(t.columns, suggest)
+		}
+		if !seenColumns[cm.Name+" "+cm.Type] {
+			seenColumns[cm.Name+" "+cm.Type] = true
+			promptColumnSuggests = append(promptColumnSuggests, suggest)
+		}
 	}
+	promptSuggest = append(promptSuggest, promptColumnSuggests...)
 
 	// App commands
+	promptCommandSuggests = make([]prompt.Suggest, 0, len(commands))
 	for _, cmd := range commands {
-		promptSuggest = append(promptSuggest, prompt.Suggest{
+		promptCommandSuggests = append(promptCommandSuggests, prompt.Suggest{
 			Text:        cmd[0],
 			Description: cmd[1],
 		})
 	}
-
-	// Some sql keywords
-	for _, kw := range sqlKeywords {
-		promptSuggest = append(promptSuggest, prompt.Suggest{
-			Text:        kw,
-			Description: "SQL key word",
-		})
-	}
+	promptSuggest = append(promptSuggest, promptCommandSuggests...)
 
 	// Some customSuggests
 	for _, custom := range customSuggests {
@@ -70,35 +104,17 @@ func initPromptSuggest(tms []*TableMeta, cms []*ColumnMeta) {
 		})
 	}
 
-	// Same dir sql files
-	sqlFileNames := sqlFileNamesInCurrentDir()
-	for _, sqlFileName := range sqlFileNames {
-		promptSuggest = append(promptSuggest, prompt.Suggest{
-			Text:        sqlFileName,
-			Description: "SQL file",
-		})
+	var dsSuggests []prompt.Suggest
+	if sqler != nil {
+		dsSuggests = dataSourceSuggests()
 	}
+	setPromptKnownWords(promptTableSuggests, promptColumnSuggests, promptCommandSuggests, dsSuggests)
 }
 
 func cliCommandSuggests() [][]string {
 	return pkg.CommandSuggests()
 }
 
-func sqlFileNamesInCurrentDir() []string {
-	matches, err := filepath.Glob("*.sql")
-	if err != nil {
-		return nil
-	}
-	return matches
-}
-
-func sqlKeyWords() []string {
-	return []string{
-		"SELECT", "select", "UPDATE", "update", "INSERT INTO", "insert into", "WHERE", "where",
-		"FROM", "from", "GROUP BY", "group by", "HAVING", "having", "LIMIT", "limit", "join", "JOIN",
-		"left join", "LEFT JOIN", "right join", "RIGHT join"}
-}
-
 func loadCustomSuggests(promptFile string) []string {
 	sqlFile, err := os.Open(promptFile)
 	if err != nil {
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"github.com/elk-language/go-prompt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sqler/pkg"
+	"strings"
+)
+
+// cmdArgCompleter returns the suggestions of the argument after args
+type cmdArgCompleter func(args []string, word string) []prompt.Suggest
+
+// cmdArgCompleters complete the arguments of commands, commands without args are not included
+var cmdArgCompleters = map[string]cmdArgCompleter{
+	pkg.CmdSource: func(_ []string, word string) []prompt.Suggest {
+		return pathSuggests(word, ".sql")
+	},
+	pkg.CmdActive: func(args []string, word string) []prompt.Suggest {
+		if len(args) > 0 {
+			return nil
+		}
+		return pathSuggests(word, ".yml", ".yaml")
+	},
+	pkg.CmdExportCsv: func(args []string, word string) []prompt.Suggest {
+		switch {
+		case len(args) == 0:
+			return pathSuggests(word, ".csv")
+		case len(args) == 1 && !strings.HasPrefix(word, `"`):
+			return pathSuggests(word, ".sql")
+		}
+		return nil
+	},
+	pkg.CmdCount: func(args []string, word string) []prompt.Suggest {
+		switch {
+		case len(args) == 0:
+			return pathSuggests(word, ".csv")
+		case containsFold(args, "where"):
+			return nil
+		}
+		return append(slices.Clip(promptTableSuggests), prompt.Suggest{Text: "where", Description: "Count rows matching condition"})
+	},
+	pkg.CmdSchemaDiff: func(args []string, _ string) []prompt.Suggest {
+		if len(args) == 0 {
+			return append(dataSourceSuggests(), promptTableSuggests...)
+		}
+		return promptTableSuggests
+	},
+	pkg.CmdDupCheck: func(args []string, _ string) []prompt.Suggest {
+		if len(args) == 0 {
+			return promptTableSuggests
+		}
+		if t, ok := promptTables[strings.ToLower(args[0])]; ok {
+			return t.columns
+		}
+		return nil
+	},
+	pkg.CmdDesc: func(args []string, _ string) []prompt.Suggest {
+		if len(args) > 0 {
+			return nil
+		}
+		return promptTableSuggests
+	},
+	pkg.CmdOrphan: func(_ []string, _ string) []prompt.Suggest {
+		suggests := make([]prompt.Suggest, 0)
+		for _, relation := range sqler.cfg.CommandsConfig.Relations {
+			suggests = append(suggests, prompt.Suggest{
+				Text:        relation.RelationName(),
+				Description: relation.Child + " -> " + relation.Parent + "[relation]",
+			})
+		}
+		return suggests
+	},
+}
+
+// cmdSuggests returns the suggestions of the command or its argument being typed and the word
+// they replace, before is the command line before cursor
+func cmdSuggests(before string) ([]prompt.Suggest, string) {
+	fields := strings.Fields(before)
+	word := ""
+	if !strings.HasSuffix(before, " ") {
+		word, fields = fields[len(fields)-1], fields[:len(fields)-1]
+	}
+	if len(fields) == 0 {
+		return promptCommandSuggests, word
+	}
+	complete, ok := cmdArgCompleters[fields[0]]
+	if !ok {
+		return nil, word
+	}
+	return complete(fields[1:], word), word
+}
+
+// pathSuggests lists the directories and the files of exts in the directory of word
+func pathSuggests(word string, exts ...string) []prompt.Suggest {
+	dir, base := filepath.Split(word)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	suggests := make([]prompt.Suggest, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		// Hidden files are listed if asked
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			suggests = append(suggests, prompt.Suggest{Text: dir + name + string(filepath.Separator), Description: "Directory"})
+			continue
+		}
+		if containsFold(exts, filepath.Ext(name)) {
+			suggests = append(suggests, prompt.Suggest{Text: dir + name, Description: "File"})
+		}
+	}
+	return suggests
+}
+
+func dataSourceSuggests() []prompt.Suggest {
+	suggests := make([]prompt.Suggest, 0, len(sqler.cfg.DataSources))
+	for _, ds := range sqler.cfg.DataSources {
+		suggests = append(suggests, prompt.Suggest{Text: ds.Name(), Description: ds.DsKey() + "[datasource]"})
+	}
+	return suggests
+}
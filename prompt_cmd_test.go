@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/elk-language/go-prompt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCmdSuggests(t *testing.T) {
+	as := assert.New(t)
+	initPromptSuggest([]*TableMeta{{Name: "users"}}, []*ColumnMeta{{Table: "users", Name: "id", Type: "int"}})
+	dir := t.TempDir()
+	as.NoError(os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	for _, name := range []string{"a.sql", "b.csv", "c.yml", ".d.sql"} {
+		as.NoError(os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	texts := func(suggests []prompt.Suggest) []string {
+		names := make([]string, 0, len(suggests))
+		for _, s := range suggests {
+			names = append(names, s.Text)
+		}
+		return names
+	}
+
+	suggests, word := cmdSuggests("/sou")
+	as.Equal("/sou", word)
+	as.Contains(texts(suggests), "/source")
+	suggests, word = cmdSuggests("/source " + dir + "/")
+	as.Equal(dir+"/", word)
+	as.Equal([]string{dir + "/a.sql", dir + "/sub" + string(filepath.Separator)}, texts(suggests))
+	suggests, _ = cmdSuggests("/active " + dir + "/")
+	as.Equal([]string{dir + "/c.yml", dir + "/sub" + string(filepath.Separator)}, texts(suggests))
+	suggests, _ = cmdSuggests("/count r.csv ")
+	as.Equal([]string{"users", "where"}, texts(suggests))
+	suggests, _ = cmdSuggests("/count r.csv users where ")
+	as.Empty(suggests)
+	suggests, _ = cmdSuggests("/dupcheck users ")
+	as.Equal([]string{"id"}, texts(suggests))
+	suggests, _ = cmdSuggests("/clear ")
+	as.Empty(suggests)
+}
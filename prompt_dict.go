@@ -0,0 +1,107 @@
+package main
+
+import (
+	"embed"
+	"github.com/elk-language/go-prompt"
+	"strings"
+)
+
+// sqlDictFiles are the built-in words of all dialects (common.txt) and each datasource type
+//
+//go:embed dict/*.txt
+var sqlDictFiles embed.FS
+
+// sqlDict is the keywords, functions and types completed in sql, texts are upper case
+type sqlDict struct {
+	keywords  []prompt.Suggest
+	functions []prompt.Suggest
+	types     []prompt.Suggest
+	// words are the upper case words of keywords, the case of them tells the case the user types
+	words map[string]bool
+	seen  map[string]bool
+}
+
+// loadSqlDict loads the built-in words of dsTypes, then the custom words of prompt_dict.txt and
+// prompt_dict_<type>.txt in the working directory, the same word of a kind is kept once
+func loadSqlDict(dsTypes ...string) *sqlDict {
+	dict := &sqlDict{words: make(map[string]bool), seen: make(map[string]bool)}
+	builtin := func(name string) []string {
+		data, err := sqlDictFiles.ReadFile("dict/" + name + ".txt")
+		if err != nil {
+			return nil
+		}
+		return strings.Split(string(data), "\n")
+	}
+	dict.add(builtin("common"))
+	dict.add(loadCustomSuggests("prompt_dict.txt"))
+	for _, dsType := range dsTypes {
+		dict.add(builtin(dsType))
+		dict.add(loadCustomSuggests("prompt_dict_" + dsType + ".txt"))
+	}
+	return dict
+}
+
+// add parses lines of kind|text|description, blank lines and lines starting with # are skipped
+func (dict *sqlDict) add(lines []string) {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "|", 3)
+		if len(parts) < 2 {
+			continue
+		}
+		kind, text := strings.ToLower(strings.TrimSpace(parts[0])), strings.ToUpper(strings.TrimSpace(parts[1]))
+		if text == "" || dict.seen[kind+" "+text] {
+			continue
+		}
+		dict.seen[kind+" "+text] = true
+		description := ""
+		if len(parts) == 3 {
+			description = strings.TrimSpace(parts[2])
+		}
+		suggest := prompt.Suggest{Text: text, Description: description + "[" + kind + "]"}
+		switch kind {
+		case "keyword":
+			dict.keywords = append(dict.keywords, suggest)
+			for _, word := range strings.Fields(text) {
+				dict.words[word] = true
+			}
+		case "function":
+			dict.functions = append(dict.functions, suggest)
+		case "type":
+			dict.types = append(dict.types, suggest)
+		}
+	}
+}
+
+// lowerCase tells if the user types in lower case by word, or the last keyword of tokens if word
+// has no letters. Upper case is the default
+func (dict *sqlDict) lowerCase(word string, tokens []string) bool {
+	if strings.ToLower(word) == strings.ToUpper(word) {
+		word = ""
+		for i := len(tokens) - 1; i >= 0; i-- {
+			if dict.words[strings.ToUpper(tokens[i])] {
+				word = tokens[i]
+				break
+			}
+		}
+	}
+	return word != "" && word == strings.ToLower(word)
+}
+
+// casedSuggests returns suggests in lower case if lower, otherwise suggests themselves
+func casedSuggests(lower bool, suggests ...[]prompt.Suggest) []prompt.Suggest {
+	cased := make([]prompt.Suggest, 0)
+	for _, s := range suggests {
+		if !lower {
+			cased = append(cased, s...)
+			continue
+		}
+		for _, suggest := range s {
+			cased = append(cased, prompt.Suggest{Text: strings.ToLower(suggest.Text), Description: suggest.Description})
+		}
+	}
+	return cased
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/elk-language/go-prompt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSqlDict(t *testing.T) {
+	as := assert.New(t)
+	texts := func(suggests []prompt.Suggest) []string {
+		names := make([]string, 0, len(suggests))
+		for _, s := range suggests {
+			names = append(names, s.Text)
+		}
+		return names
+	}
+	dict := loadSqlDict("mysql")
+	as.Contains(texts(dict.keywords), "GROUP BY")
+	as.NotContains(texts(dict.keywords), "group by")
+	as.Contains(texts(dict.functions), "DATE_FORMAT")
+	as.NotContains(texts(loadSqlDict("sqlite3").functions), "DATE_FORMAT")
+
+	functionSize := len(dict.functions)
+	dict.add([]string{"# custom", "", "function | my_fn | MY_FN(x) custom", "function|count|duplicated", "type"})
+	as.Len(dict.functions, functionSize+1)
+	as.Equal(prompt.Suggest{Text: "MY_FN", Description: "MY_FN(x) custom[function]"}, dict.functions[functionSize])
+
+	as.True(dict.lowerCase("sel", nil))
+	as.False(dict.lowerCase("Sel", nil))
+	as.True(dict.lowerCase("", []string{"select", "*", "from", "t"}))
+	as.False(dict.lowerCase("", []string{"SELECT", "a"}))
+	as.False(dict.lowerCase("", nil))
+	as.Equal([]string{"select", "from"}, texts(casedSuggests(true, dict.keywords[:2])))
+	as.Equal([]string{"SELECT", "FROM"}, texts(casedSuggests(false, dict.keywords[:2])))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"github.com/elk-language/go-prompt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// promptRecentFile records the words of executed lines, the most recent is the last
+	promptRecentFile = "prompt_recent.txt"
+	promptRecentSize = 500
+)
+
+// The tiers of match quality, the recency, kind and gaps adjust the score within a tier
+const (
+	matchSubsequence = 400 + 200*iota
+	matchSubstring
+	matchPrefix
+	matchExact
+)
+
+var (
+	// promptRecent are the lower case words used recently, the most recent is the last
+	promptRecent []string
+	// promptRecentAge is the number of words used after the word
+	promptRecentAge map[string]int
+	// promptKnownWords are the lower case names of tables, columns, datasources and commands,
+	// only they are recorded as recent words
+	promptKnownWords map[string]bool
+)
+
+// suggestKinds rank the suggestions of the same match quality by the tag of description,
+// suggestions without tag like columns rank as 4
+var suggestKinds = map[string]int{
+	"[alias]":    5,
+	"[table]":    3,
+	"[function]": 2,
+	"[keyword]":  1,
+	"[type]":     1,
+}
+
+// rankSuggests returns the suggests which match typed fuzzily, the better matches, recently used
+// words and closer kinds first
+func rankSuggests(suggests []prompt.Suggest, typed string) []prompt.Suggest {
+	type ranked struct {
+		suggest prompt.Suggest
+		score   int
+	}
+	matched := make([]ranked, 0, len(suggests))
+	for _, s := range suggests {
+		score, ok := matchScore(s.Text, typed)
+		if !ok {
+			continue
+		}
+		matched = append(matched, ranked{s, score + recencyScore(s.Text) + 5*suggestKind(s.Description)})
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].score > matched[j].score
+	})
+	result := make([]prompt.Suggest, len(matched))
+	for i, m := range matched {
+		result[i] = m.suggest
+	}
+	return result
+}
+
+// matchScore scores text by typed case-insensitively, false if typed is not a subsequence of text.
+// Exact match is the best, then prefix, substring and subsequence, less skipped chars are better
+func matchScore(text string, typed string) (int, bool) {
+	t, w := strings.ToLower(text), strings.ToLower(typed)
+	if w == "" {
+		return matchPrefix, true
+	}
+	if t == w {
+		return matchExact, true
+	}
+	if strings.HasPrefix(t, w) {
+		return matchPrefix - min(len(t)-len(w), 24), true
+	}
+	if i := strings.Index(t, w); i >= 0 {
+		return matchSubstring - min(i, 24), true
+	}
+	// The chars skipped before the last matched char
+	skipped, j := 0, 0
+	for i := 0; i < len(t) && j < len(w); i++ {
+		if t[i] == w[j] {
+			j++
+		} else {
+			skipped++
+		}
+	}
+	if j < len(w) {
+		return 0, false
+	}
+	return matchSubsequence - min(skipped, 24), true
+}
+
+// recencyScore is 150 for the most recent word and less for the older ones, 0 if not used
+func recencyScore(text string) int {
+	key := strings.ToLower(text)
+	if fields := strings.Fields(key); len(fields) > 1 {
+		key = fields[0]
+	}
+	age, ok := promptRecentAge[key]
+	if !ok {
+		return 0
+	}
+	return max(150-age, 20)
+}
+
+func suggestKind(description string) int {
+	if i := strings.LastIndex(description, "["); i >= 0 && strings.HasSuffix(description, "]") {
+		return suggestKinds[description[i:]]
+	}
+	return 4
+}
+
+var identQuotes = strings.NewReplacer("`", "", `"`, "")
+
+// recordRecent records the known names in executed line as the most recent words, the command and
+// arguments of a command line or the identifiers of sql
+func recordRecent(line string) {
+	words := make([]string, 0)
+	if strings.HasPrefix(line, "/") {
+		words = append(words, strings.Fields(line)...)
+	} else {
+		for _, token := range sqlTokens(line) {
+			if isSqlIdent(token) {
+				words = append(words, strings.Split(identQuotes.Replace(token), ".")...)
+			}
+		}
+	}
+	known := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.ToLower(word)
+		if promptKnownWords[word] && !slices.Contains(known, word) {
+			known = append(known, word)
+		}
+	}
+	if len(known) == 0 {
+		return
+	}
+	recent := make([]string, 0, len(promptRecent)+len(known))
+	for _, word := range promptRecent {
+		if !slices.Contains(known, word) {
+			recent = append(recent, word)
+		}
+	}
+	recent = append(recent, known...)
+	setPromptRecent(recent[max(len(recent)-promptRecentSize, 0):])
+}
+
+// setPromptKnownWords sets the texts of suggests as the known words
+func setPromptKnownWords(suggests ...[]prompt.Suggest) {
+	promptKnownWords = make(map[string]bool)
+	for _, s := range suggests {
+		for _, suggest := range s {
+			promptKnownWords[strings.ToLower(suggest.Text)] = true
+		}
+	}
+}
+
+func setPromptRecent(recent []string) {
+	promptRecent = recent
+	promptRecentAge = make(map[string]int, len(recent))
+	for i, word := range recent {
+		promptRecentAge[word] = len(recent) - 1 - i
+	}
+}
+
+func loadPromptRecent() {
+	setPromptRecent(loadCustomSuggests(promptRecentFile))
+}
+
+func savePromptRecent() error {
+	return os.WriteFile(promptRecentFile, []byte(strings.Join(promptRecent, "\n")), 0644)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/elk-language/go-prompt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRankSuggests(t *testing.T) {
+	as := assert.New(t)
+	defer setPromptRecent(nil)
+	setPromptRecent(nil)
+	suggests := []prompt.Suggest{
+		{Text: "user_name", Description: "varchar(32)"},
+		{Text: "orders", Description: "[table]"},
+		{Text: "order_id", Description: "int"},
+		{Text: "user", Description: "[table]"},
+		{Text: "ORDER BY", Description: "[keyword]"},
+	}
+	texts := func(typed string) []string {
+		names := make([]string, 0)
+		for _, s := range rankSuggests(suggests, typed) {
+			names = append(names, s.Text)
+		}
+		return names
+	}
+	as.Equal([]string{"user_name"}, texts("usrname"))
+	as.Equal([]string{"order_id", "orders", "ORDER BY"}, texts("ordr"))
+	as.Equal([]string{"user", "user_name"}, texts("USER"))
+	as.Equal([]string{"order_id", "orders", "ORDER BY"}, texts("order"))
+	as.Equal([]string{"user_name", "order_id"}, texts("_"))
+
+	// Only the known names are recorded
+	defer setPromptKnownWords()
+	setPromptKnownWords(suggests, []prompt.Suggest{{Text: "/count"}})
+	recordRecent("select * from `orders` o order by o.id")
+	as.Equal([]string{"orders"}, promptRecent)
+	as.Equal([]string{"orders", "order_id", "ORDER BY"}, texts("order"))
+	recordRecent("/count User a.csv")
+	recordRecent("select 1")
+	as.Equal([]string{"orders", "/count", "user"}, promptRecent)
+	as.Equal(0, promptRecentAge["user"])
+	as.Equal(2, promptRecentAge["orders"])
+}
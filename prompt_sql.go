@@ -0,0 +1,234 @@
+package main
+
+import (
+	"github.com/elk-language/go-prompt"
+	"slices"
+	"strings"
+)
+
+// sqlClause is the kind of object completed at the cursor
+type sqlClause int
+
+const (
+	clauseAny sqlClause = iota
+	clauseTable
+	clauseColumn
+	clauseKeyword
+	clauseNone
+)
+
+// sqlTableKeywords are followed by table names
+var sqlTableKeywords = map[string]bool{"FROM": true, "JOIN": true, "UPDATE": true, "INTO": true, "TABLE": true}
+
+// sqlClauseKeywords are the keywords which start a clause or are never an alias
+var sqlClauseKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true,
+	"OUTER": true, "CROSS": true, "FULL": true, "ON": true, "USING": true, "AND": true, "OR": true, "NOT": true,
+	"IN": true, "IS": true, "NULL": true, "LIKE": true, "BETWEEN": true, "EXISTS": true, "GROUP": true,
+	"ORDER": true, "BY": true, "HAVING": true, "LIMIT": true, "OFFSET": true, "UNION": true, "ALL": true,
+	"AS": true, "SET": true, "VALUES": true, "INTO": true, "UPDATE": true, "DELETE": true, "INSERT": true,
+	"TABLE": true, "DISTINCT": true, "CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true,
+	"ASC": true, "DESC": true,
+}
+
+// sqlSuggests returns the suggestions of the clause at the end of before and the word they
+// replace, before is the statement before cursor and stmt is the whole statement
+func sqlSuggests(before string, stmt string) ([]prompt.Suggest, string) {
+	word := lastSqlWord(before)
+	tables := referencedTables(sqlTokens(stmt))
+	// Member of alias or table
+	if i := strings.LastIndex(word, "."); i >= 0 {
+		t := promptTables[tables.resolve(word[:i])]
+		if t == nil {
+			return nil, word[i+1:]
+		}
+		return t.columns, word[i+1:]
+	}
+
+	tokens := sqlTokens(before[:len(before)-len(word)])
+	lower := promptDict.lowerCase(word, tokens)
+	switch clauseAt(tokens) {
+	case clauseTable:
+		return promptTableSuggests, word
+	case clauseColumn:
+		suggests := make([]prompt.Suggest, 0)
+		for _, name := range tables.names {
+			if t, ok := promptTables[name]; ok {
+				suggests = append(suggests, t.columns...)
+			}
+		}
+		if len(suggests) == 0 {
+			suggests = append(suggests, promptColumnSuggests...)
+		}
+		for _, alias := range tables.aliasNames {
+			suggests = append(suggests, prompt.Suggest{Text: alias, Description: tables.aliases[alias] + "[alias]"})
+		}
+		return append(suggests, casedSuggests(lower, promptDict.functions, promptDict.keywords)...), word
+	case clauseKeyword:
+		// Columns are defined by types in DDL
+		if isDdlStmt(stmt) {
+			return casedSuggests(lower, promptDict.keywords, promptDict.types), word
+		}
+		return casedSuggests(lower, promptDict.keywords), word
+	case clauseNone:
+		return nil, word
+	}
+	return append(slices.Clip(promptSuggest), casedSuggests(lower, promptDict.keywords, promptDict.functions, promptDict.types)...), word
+}
+
+// clauseAt returns the clause after tokens by the last keyword of them
+func clauseAt(tokens []string) sqlClause {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		keyword := strings.ToUpper(tokens[i])
+		if !sqlClauseKeywords[keyword] {
+			continue
+		}
+		rest := tokens[i+1:]
+		switch {
+		case keyword == "AS":
+			return clauseNone
+		case !sqlTableKeywords[keyword]:
+			return clauseColumn
+		case len(rest) == 0 || keyword == "FROM" && rest[len(rest)-1] == ",":
+			return clauseTable
+		case rest[len(rest)-1] == "(" || rest[len(rest)-1] == ",":
+			// Columns of insert into t (...)
+			return clauseColumn
+		}
+		// Alias or the next clause after table names
+		return clauseKeyword
+	}
+	return clauseAny
+}
+
+// sqlTables are the tables referenced by a statement, names are lower case
+type sqlTables struct {
+	names      []string
+	aliasNames []string
+	aliases    map[string]string
+}
+
+// resolve returns the table of alias, or the name itself if not an alias
+func (t *sqlTables) resolve(qualifier string) string {
+	name := identName(qualifier)
+	if table, ok := t.aliases[name]; ok {
+		return table
+	}
+	return name
+}
+
+// referencedTables finds the tables after FROM, JOIN, UPDATE, INTO and TABLE and the aliases of them
+func referencedTables(tokens []string) *sqlTables {
+	tables := &sqlTables{aliases: make(map[string]string)}
+	isName := func(j int) bool {
+		return j < len(tokens) && isSqlIdent(tokens[j]) && !sqlClauseKeywords[strings.ToUpper(tokens[j])]
+	}
+	for i, token := range tokens {
+		keyword := strings.ToUpper(token)
+		if !sqlTableKeywords[keyword] {
+			continue
+		}
+		for j := i + 1; isName(j); {
+			table := identName(tokens[j])
+			tables.names = append(tables.names, table)
+			j++
+			if j < len(tokens) && strings.EqualFold(tokens[j], "AS") {
+				j++
+			}
+			if isName(j) {
+				alias := identName(tokens[j])
+				if _, ok := tables.aliases[alias]; !ok {
+					tables.aliasNames = append(tables.aliasNames, alias)
+				}
+				tables.aliases[alias] = table
+				j++
+			}
+			// Tables separated by comma
+			if keyword != "FROM" || j >= len(tokens) || tokens[j] != "," {
+				break
+			}
+			j++
+		}
+	}
+	return tables
+}
+
+// sqlTokens splits sql into identifiers and symbols, every string literal is an empty literal token
+func sqlTokens(sql string) []string {
+	tokens := make([]string, 0)
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'':
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] == '\\' {
+					j++
+				} else if sql[j] == '\'' {
+					if j+1 < len(sql) && sql[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			tokens = append(tokens, "''")
+			i = j + 1
+		case isIdentByte(c) || c == '`' || c == '"':
+			j := identEnd(sql, i)
+			tokens = append(tokens, sql[i:j])
+			i = j
+		default:
+			tokens = append(tokens, sql[i:i+1])
+			i++
+		}
+	}
+	return tokens
+}
+
+// identEnd returns the end of the qualified identifier which starts at i, e.g. `db`.t
+func identEnd(sql string, i int) int {
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == '`' || c == '"':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				return len(sql)
+			}
+			i += end + 2
+		case isIdentByte(c) || c == '.':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// lastSqlWord returns the identifier at the end of before, empty if it ends with a symbol or space
+func lastSqlWord(before string) string {
+	i := len(before)
+	for i > 0 && (isIdentByte(before[i-1]) || before[i-1] == '.' || before[i-1] == '`' || before[i-1] == '"') {
+		i--
+	}
+	return before[i:]
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isSqlIdent(token string) bool {
+	return isIdentByte(token[0]) || token[0] == '`' || token[0] == '"'
+}
+
+// identName returns the lower case table name of identifier without schema and quotes
+func identName(ident string) string {
+	if i := strings.LastIndex(ident, "."); i >= 0 {
+		ident = ident[i+1:]
+	}
+	return strings.ToLower(strings.Trim(ident, "`\""))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSqlSuggests(t *testing.T) {
+	as := assert.New(t)
+	initPromptSuggest([]*TableMeta{{Name: "users", Comment: "用户"}, {Name: "orders"}}, []*ColumnMeta{
+		{Table: "users", Name: "id", Type: "int"},
+		{Table: "users", Name: "user_name", Type: "varchar(32)", Comment: "用户名"},
+		{Table: "orders", Name: "id", Type: "int"},
+		{Table: "orders", Name: "user_id", Type: "int"},
+	})
+	texts := func(before string, stmt string) ([]string, string) {
+		suggests, word := sqlSuggests(before, stmt)
+		names := make([]string, 0, len(suggests))
+		for _, s := range suggests {
+			names = append(names, s.Text)
+		}
+		return names, word
+	}
+
+	names, word := texts("select * from us", "select * from us")
+	as.Equal("us", word)
+	as.Equal([]string{"users", "orders"}, names)
+	names, _ = texts("select * from users u join ", "select * from users u join ")
+	as.Equal([]string{"users", "orders"}, names)
+
+	stmt := "select u.id, o. from users as u left join `orders` o on o.user_id = u.id"
+	names, word = texts("select u.id, o.", stmt)
+	as.Empty(word)
+	as.Equal([]string{"id", "user_id"}, names)
+	names, word = texts("select u.id, u.user_", stmt)
+	as.Equal("user_", word)
+	as.Equal([]string{"id", "user_name"}, names)
+
+	names, _ = texts("select id from users where ", "select id from users where ")
+	as.Equal([]string{"id", "user_name"}, names[:2])
+	as.NotContains(names, "user_id")
+	names, _ = texts("select ", "select ")
+	as.Equal([]string{"id", "user_name", "user_id"}, names[:3])
+	names, _ = texts("select * from users ", "select * from users ")
+	as.Contains(names, "where")
+	as.NotContains(names, "id")
+	names, _ = texts("insert into orders (", "insert into orders (")
+	as.Equal([]string{"id", "user_id"}, names[:2])
+	names, _ = texts("select 'from ", "select 'from ")
+	as.Contains(names, "user_name")
+	names, _ = texts("create table t (id ", "create table t (id ")
+	as.Contains(names, "integer")
+	names, _ = texts("SELECT * FROM users ", "SELECT * FROM users ")
+	as.Contains(names, "WHERE")
+}
+
+func TestReferencedTables(t *testing.T) {
+	as := assert.New(t)
+	tables := referencedTables(sqlTokens("select * from db.`Users` u, orders as o where u.name = 'join x'"))
+	as.Equal([]string{"users", "orders"}, tables.names)
+	as.Equal([]string{"u", "o"}, tables.aliasNames)
+	as.Equal("users", tables.resolve("U"))
+	as.Equal("orders", tables.resolve("orders"))
+}
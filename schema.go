@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SchemaTable is the structure of a table loaded from the catalog
+type SchemaTable struct {
+	Name      string
+	Engine    string
+	Collation string
+	Comment   string
+	RowsEst   int64
+	Size      int64
+	Columns   []*SchemaColumn
+	Indexes   []*SchemaIndex
+}
+
+type SchemaColumn struct {
+	Name     string
+	Type     string
+	Nullable bool
+	// Default is nil if the column has no default value
+	Default *string
+	Extra   string
+	Comment string
+}
+
+type SchemaIndex struct {
+	Name    string
+	Unique  bool
+	Columns []string
+	Type    string
+}
+
+// Column returns the column by name, nil if not found
+func (t *SchemaTable) Column(name string) *SchemaColumn {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// Index returns the index by name, nil if not found
+func (t *SchemaTable) Index(name string) *SchemaIndex {
+	for _, idx := range t.Indexes {
+		if idx.Name == name {
+			return idx
+		}
+	}
+	return nil
+}
+
+// listTables returns the names of tables in schema from the catalog
+func listTables(db sqlQueryer, dialect Dialect, schema string) ([]string, error) {
+	query, args := dialect.TableNamesQuery(schema)
+	_, rows, err := queryAsString(db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(rows))
+	for i, row := range rows {
+		names[i] = row[0]
+	}
+	return names, nil
+}
+
+// loadAllSchemaTables loads the schema tables of all datasources concurrently, the tables of
+// datasource are nil if failed
+func loadAllSchemaTables(s *Sqler) ([]map[string]*SchemaTable, error) {
+	schemas := make([]map[string]*SchemaTable, len(s.dbs))
+	errs := make([]error, len(s.dbs))
+	wg := new(sync.WaitGroup)
+	for dbIdx, db := range s.dbs {
+		ds := s.cfg.DataSources[dbIdx]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tables, err := loadSchemaTables(db, s.Dialect(dbIdx), ds.Schema)
+			if err != nil {
+				errs[dbIdx] = fmt.Errorf("failed to load schema of %s: %w", ds.DsKey(), err)
+				return
+			}
+			schemas[dbIdx] = tables
+		}()
+	}
+	wg.Wait()
+	return schemas, errors.Join(errs...)
+}
+
+// anySchemaLoaded reports whether the schema of any datasource is loaded by loadAllSchemaTables
+func anySchemaLoaded(schemas []map[string]*SchemaTable) bool {
+	return slices.ContainsFunc(schemas, func(tables map[string]*SchemaTable) bool {
+		return tables != nil
+	})
+}
+
+// loadSchemaTables loads tables, columns and indexes of schema from the catalog of dialect
+func loadSchemaTables(db *sql.DB, dialect Dialect, schema string) (map[string]*SchemaTable, error) {
+	tablesQuery, columnsQuery, indexesQuery, args := dialect.SchemaQueries(schema)
+	tables := make(map[string]*SchemaTable)
+	rows, err := db.Query(tablesQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		t := &SchemaTable{}
+		if err := rows.Scan(&t.Name, &t.Engine, &t.Collation, &t.Comment, &t.RowsEst, &t.Size); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		tables[t.Name] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(columnsQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var tableName, nullable string
+		var defaultValue sql.NullString
+		c := &SchemaColumn{}
+		if err := rows.Scan(&tableName, &c.Name, &c.Type, &nullable, &defaultValue, &c.Extra, &c.Comment); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		c.Nullable = nullable == "YES"
+		if defaultValue.Valid {
+			c.Default = &defaultValue.String
+		}
+		if t, ok := tables[tableName]; ok {
+			t.Columns = append(t.Columns, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(indexesQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var tableName, indexName, columnName, indexType string
+		var nonUnique int
+		if err := rows.Scan(&tableName, &indexName, &nonUnique, &columnName, &indexType); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		t, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+		idx := t.Index(indexName)
+		if idx == nil {
+			idx = &SchemaIndex{Name: indexName, Unique: nonUnique == 0, Type: indexType}
+			t.Indexes = append(t.Indexes, idx)
+		}
+		idx.Columns = append(idx.Columns, columnName)
+	}
+	return tables, rows.Err()
+}
+
+// Definition returns the column definition used by CREATE and ALTER TABLE
+func (c *SchemaColumn) Definition(d Dialect) string {
+	var sb strings.Builder
+	sb.WriteString(d.QuoteIdent(c.Name))
+	sb.WriteString(" ")
+	sb.WriteString(c.Type)
+	if c.Nullable {
+		sb.WriteString(" NULL")
+	} else {
+		sb.WriteString(" NOT NULL")
+	}
+	if c.Default != nil {
+		sb.WriteString(" DEFAULT ")
+		if strings.Contains(c.Extra, "DEFAULT_GENERATED") || strings.HasPrefix(strings.ToUpper(*c.Default), "CURRENT_TIMESTAMP") {
+			sb.WriteString(*c.Default)
+		} else {
+			sb.WriteString(d.Literal(*c.Default))
+		}
+	} else if c.Nullable && !strings.Contains(c.Extra, "auto_increment") {
+		sb.WriteString(" DEFAULT NULL")
+	}
+	sb.WriteString(d.ColumnOptions(c))
+	return sb.String()
+}
+
+// Definition returns the index definition used by CREATE TABLE and ALTER TABLE ADD
+func (idx *SchemaIndex) Definition(d Dialect) string {
+	cols := make([]string, len(idx.Columns))
+	for i, col := range idx.Columns {
+		cols[i] = d.QuoteIdent(col)
+	}
+	switch {
+	case idx.Name == "PRIMARY":
+		return fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(cols, ","))
+	case idx.Type == "FULLTEXT" || idx.Type == "SPATIAL":
+		return fmt.Sprintf("%s INDEX %s (%s)", idx.Type, d.QuoteIdent(idx.Name), strings.Join(cols, ","))
+	case idx.Unique:
+		return fmt.Sprintf("UNIQUE INDEX %s (%s)", d.QuoteIdent(idx.Name), strings.Join(cols, ","))
+	}
+	return fmt.Sprintf("INDEX %s (%s)", d.QuoteIdent(idx.Name), strings.Join(cols, ","))
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sqler/pkg"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// SchemaDiffJob compares the table structures of every datasource to the base datasource and
+// generates the scripts which bring each datasource in line with the base
+type SchemaDiffJob struct {
+	sqler  *Sqler
+	base   string
+	tables []string
+	*BaseJob
+}
+
+func NewSchemaDiffJob(sqler *Sqler, base string, tables []string) Job {
+	return &SchemaDiffJob{
+		sqler:   sqler,
+		base:    base,
+		tables:  tables,
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+// schemaDiff is a difference of table structure
+type schemaDiff struct {
+	Table     string
+	Object    string
+	Name      string
+	Attribute string
+	Base      string
+	Value     string
+}
+
+func (job *SchemaDiffJob) Exec() {
+	baseIdx := 0
+	if job.base != "" {
+		var err error
+		if baseIdx, err = job.sqler.cfg.DataSourceIndex(job.base); job.RecordError(err) {
+			return
+		}
+	}
+	if err := os.Mkdir("schema_diff", 0755); err != nil && !os.IsExist(err) {
+		job.RecordError(err)
+		return
+	}
+
+	// Load table structures
+	schemas := make([]map[string]*SchemaTable, len(job.sqler.dbs))
+	for dbIdx, db := range job.sqler.dbs {
+		ds := job.sqler.cfg.DataSources[dbIdx]
+		printer.Info(fmt.Sprintf("[%s] Loading schema %s (%d/%d)", pkg.Now(), ds.DsKey(), dbIdx+1, len(job.sqler.dbs)))
+		tables, err := loadSchemaTables(db, job.sqler.Dialect(dbIdx), ds.Schema)
+		if job.RecordError(err) {
+			return
+		}
+		schemas[dbIdx] = tables
+	}
+	names := job.tables
+	if len(names) == 0 {
+		seen := make(map[string]bool)
+		for _, tables := range schemas {
+			for name := range tables {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+	}
+
+	reportFileName := "schema_diff/report.csv"
+	reportFile, err := os.OpenFile(reportFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0665)
+	if job.RecordError(err) {
+		return
+	}
+	defer reportFile.Close()
+	report := csv.NewWriter(reportFile)
+	if job.RecordError(report.Write([]string{"Table", "Object", "Name", "Attribute", "DataSource", "Base", "Value"})) {
+		return
+	}
+
+	b := new(bytes.Buffer)
+	summary := tablewriter.NewWriter(b)
+	summary.SetHeader([]string{"DataSource", "Differences", "Script"})
+	baseDs := job.sqler.cfg.DataSources[baseIdx]
+	for dbIdx, ds := range job.sqler.cfg.DataSources {
+		if dbIdx == baseIdx {
+			continue
+		}
+		diffs, stmts := diffSchemaTables(job.sqler.Dialect(dbIdx), schemas[baseIdx], schemas[dbIdx], names)
+		for _, d := range diffs {
+			if job.RecordError(report.Write([]string{d.Table, d.Object, d.Name, d.Attribute, ds.DsKey(), d.Base, d.Value})) {
+				return
+			}
+		}
+		scriptFileName := ""
+		if len(stmts) > 0 {
+			scriptFileName = fmt.Sprintf("schema_diff/%d.%s.sql", dbIdx, fileNameOf(ds.Name()))
+			header := fmt.Sprintf("-- Bring %s in line with %s, generated at %s\n-- DROP statements are commented out to avoid data loss\n",
+				ds.DsKey(), baseDs.DsKey(), pkg.Now())
+			script := header + strings.Join(stmts, "\n") + "\n"
+			if job.RecordError(os.WriteFile(scriptFileName, []byte(script), 0665)) {
+				return
+			}
+		}
+		summary.Append([]string{ds.DsKey(), strconv.Itoa(len(diffs)), scriptFileName})
+	}
+	report.Flush()
+	if job.RecordError(report.Error()) {
+		return
+	}
+	summary.Render()
+	job.PrintAfterDone(b.String())
+	job.PrintAfterDone(fmt.Sprintf("[%s] Compared %d tables with base %s, report saved to %s",
+		pkg.Now(), len(names), baseDs.DsKey(), reportFileName))
+}
+
+// diffSchemaTables compares tables of target to base, returns the differences and the
+// statements in dialect d of target which make target the same as base
+func diffSchemaTables(d Dialect, base, target map[string]*SchemaTable, names []string) ([]*schemaDiff, []string) {
+	diffs := make([]*schemaDiff, 0)
+	stmts := make([]string, 0)
+	for _, name := range names {
+		baseTable, targetTable := base[name], target[name]
+		switch {
+		case baseTable == nil && targetTable == nil:
+			continue
+		case baseTable == nil:
+			diffs = append(diffs, &schemaDiff{Table: name, Object: "TABLE", Name: name, Attribute: "EXTRA"})
+			stmts = append(stmts, fmt.Sprintf("-- DROP TABLE %s;", d.QuoteIdent(name)))
+		case targetTable == nil:
+			diffs = append(diffs, &schemaDiff{Table: name, Object: "TABLE", Name: name, Attribute: "MISSING"})
+			stmts = append(stmts, d.CreateTableSql(baseTable))
+		default:
+			tableDiffs, tableStmts := diffSchemaTable(d, baseTable, targetTable)
+			diffs = append(diffs, tableDiffs...)
+			stmts = append(stmts, tableStmts...)
+		}
+	}
+	return diffs, stmts
+}
+
+func diffSchemaTable(d Dialect, base, target *SchemaTable) ([]*schemaDiff, []string) {
+	diffs := make([]*schemaDiff, 0)
+	stmts := make([]string, 0)
+	table := base.Name
+
+	// Columns
+	for i, baseCol := range base.Columns {
+		col := target.Column(baseCol.Name)
+		if col == nil {
+			diffs = append(diffs, &schemaDiff{Table: table, Object: "COLUMN", Name: baseCol.Name, Attribute: "MISSING"})
+			after := ""
+			if i > 0 {
+				after = base.Columns[i-1].Name
+			}
+			stmts = append(stmts, d.AddColumnSql(table, baseCol, after))
+			continue
+		}
+		colDiffs := []*schemaDiff{
+			{Attribute: "TYPE", Base: baseCol.Type, Value: col.Type},
+			{Attribute: "NULLABLE", Base: strconv.FormatBool(baseCol.Nullable), Value: strconv.FormatBool(col.Nullable)},
+			{Attribute: "DEFAULT", Base: defaultString(baseCol.Default), Value: defaultString(col.Default)},
+			{Attribute: "EXTRA", Base: baseCol.Extra, Value: col.Extra},
+			{Attribute: "COMMENT", Base: baseCol.Comment, Value: col.Comment},
+		}
+		modified := false
+		for _, d := range colDiffs {
+			if d.Base != d.Value {
+				d.Table, d.Object, d.Name = table, "COLUMN", baseCol.Name
+				diffs = append(diffs, d)
+				modified = true
+			}
+		}
+		if modified {
+			stmts = append(stmts, d.ModifyColumnSql(table, baseCol))
+		}
+	}
+	for _, col := range target.Columns {
+		if base.Column(col.Name) == nil {
+			diffs = append(diffs, &schemaDiff{Table: table, Object: "COLUMN", Name: col.Name, Attribute: "EXTRA"})
+			stmts = append(stmts, commentOut(d.DropColumnSql(table, col.Name)))
+		}
+	}
+
+	// Indexes
+	for _, baseIdx := range base.Indexes {
+		idx := target.Index(baseIdx.Name)
+		if idx == nil {
+			diffs = append(diffs, &schemaDiff{Table: table, Object: "INDEX", Name: baseIdx.Name, Attribute: "MISSING"})
+			stmts = append(stmts, d.AddIndexSql(table, baseIdx))
+			continue
+		}
+		if baseDef, def := baseIdx.Definition(d), idx.Definition(d); baseDef != def {
+			diffs = append(diffs, &schemaDiff{Table: table, Object: "INDEX", Name: baseIdx.Name, Attribute: "DEFINITION",
+				Base: baseDef, Value: def})
+			stmts = append(stmts, d.ReplaceIndexSql(table, baseIdx))
+		}
+	}
+	for _, idx := range target.Indexes {
+		if base.Index(idx.Name) == nil {
+			diffs = append(diffs, &schemaDiff{Table: table, Object: "INDEX", Name: idx.Name, Attribute: "EXTRA"})
+			stmts = append(stmts, commentOut(d.DropIndexSql(table, idx)))
+		}
+	}
+
+	// Table options
+	options := make([]string, 0)
+	for _, diff := range []*schemaDiff{
+		{Attribute: "ENGINE", Base: base.Engine, Value: target.Engine},
+		{Attribute: "COLLATION", Base: base.Collation, Value: target.Collation},
+		{Attribute: "COMMENT", Base: base.Comment, Value: target.Comment},
+	} {
+		if diff.Base != diff.Value {
+			diff.Table, diff.Object, diff.Name = table, "TABLE", table
+			diffs = append(diffs, diff)
+			options = append(options, diff.Attribute)
+		}
+	}
+	if len(options) > 0 {
+		stmts = append(stmts, d.TableOptionsSql(base, options))
+	}
+	return diffs, stmts
+}
+
+// commentOut comments out stmt which may lose data, notes are comments already
+func commentOut(stmt string) string {
+	if strings.HasPrefix(stmt, "--") {
+		return stmt
+	}
+	return "-- " + stmt
+}
+
+func defaultString(v *string) string {
+	if v == nil {
+		return "(none)"
+	}
+	return *v
+}
+
+var invalidFileNameChars = regexp.MustCompile(`[^\w.-]+`)
+
+// fileNameOf replaces the chars which are not safe in file names, e.g. "127.0.0.1:3306/db" to "127.0.0.1_3306_db"
+func fileNameOf(name string) string {
+	name = invalidFileNameChars.ReplaceAllString(name, "_")
+	return strings.Trim(name, "_")
+}
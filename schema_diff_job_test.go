@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffSchemaTables(t *testing.T) {
+	as := assert.New(t)
+	zero := "0"
+	base := map[string]*SchemaTable{
+		"a": {
+			Name: "a", Engine: "InnoDB", Comment: "表A",
+			Columns: []*SchemaColumn{
+				{Name: "id", Type: "bigint", Extra: "auto_increment"},
+				{Name: "name", Type: "varchar(64)", Nullable: true, Comment: "名称"},
+				{Name: "status", Type: "int", Default: &zero},
+			},
+			Indexes: []*SchemaIndex{
+				{Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+				{Name: "idx_name", Columns: []string{"name"}},
+			},
+		},
+		"b": {
+			Name: "b", Engine: "InnoDB",
+			Columns: []*SchemaColumn{{Name: "id", Type: "int"}},
+			Indexes: []*SchemaIndex{{Name: "PRIMARY", Unique: true, Columns: []string{"id"}}},
+		},
+	}
+	target := map[string]*SchemaTable{
+		"a": {
+			Name: "a", Engine: "InnoDB", Comment: "",
+			Columns: []*SchemaColumn{
+				{Name: "id", Type: "bigint", Extra: "auto_increment"},
+				{Name: "name", Type: "varchar(32)", Nullable: true, Comment: "名称"},
+				{Name: "tmp", Type: "int", Nullable: true},
+			},
+			Indexes: []*SchemaIndex{
+				{Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+				{Name: "idx_name", Unique: true, Columns: []string{"name"}},
+			},
+		},
+		"c": {Name: "c"},
+	}
+	diffs, stmts := diffSchemaTables(mysqlDialect{}, base, target, []string{"a", "b", "c"})
+	as.Len(diffs, 7)
+	as.Equal([]string{
+		"ALTER TABLE `a` MODIFY COLUMN `name` varchar(64) NULL DEFAULT NULL COMMENT '名称';",
+		"ALTER TABLE `a` ADD COLUMN `status` int NOT NULL DEFAULT '0' AFTER `name`;",
+		"-- ALTER TABLE `a` DROP COLUMN `tmp`;",
+		"ALTER TABLE `a` DROP INDEX `idx_name`, ADD INDEX `idx_name` (`name`);",
+		"ALTER TABLE `a` COMMENT='表A';",
+		"CREATE TABLE `b` (\n  `id` int NOT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB;",
+		"-- DROP TABLE `c`;",
+	}, stmts)
+}
+
+func TestDiffSchemaTablesSqlite(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	open := func(name string, ddl string) (*sql.DB, map[string]*SchemaTable) {
+		db, err := sql.Open("sqlite3", "file:"+filepath.Join(dir, name))
+		as.NoError(err)
+		_, err = db.Exec(ddl)
+		as.NoError(err)
+		tables, err := loadSchemaTables(db, sqliteDialect{}, name)
+		as.NoError(err)
+		return db, tables
+	}
+	baseDb, base := open("base.sqlite", `create table a (id integer primary key autoincrement, name text default 'x', n int);
+create index a_name on a (name);
+create unique index a_n on a (n, id);
+create table b (id int, code text not null, primary key (id));
+create unique index b_code on b (code);`)
+	defer baseDb.Close()
+	targetDb, target := open("target.sqlite", `create table a (id integer primary key autoincrement, name int, tmp int);
+create index a_n on a (name);`)
+	defer targetDb.Close()
+
+	_, stmts := diffSchemaTables(sqliteDialect{}, base, target, []string{"a", "b"})
+	as.Equal([]string{
+		`-- SQLite cannot modify column to "name" TEXT NULL DEFAULT 'x', rebuild "a": create a new table with the base definition, copy rows, drop the old table and rename the new one`,
+		`ALTER TABLE "a" ADD COLUMN "n" INT NULL DEFAULT NULL;`,
+		`-- ALTER TABLE "a" DROP COLUMN "tmp";`,
+		`DROP INDEX "a_n";` + "\n" + `CREATE UNIQUE INDEX "a_n" ON "a" ("n","id");`,
+		`CREATE INDEX "a_name" ON "a" ("name");`,
+		"CREATE TABLE \"b\" (\n  \"id\" INT NOT NULL,\n  \"code\" TEXT NOT NULL,\n  PRIMARY KEY (\"id\")\n);\n" +
+			`CREATE UNIQUE INDEX "b_code" ON "b" ("code");`,
+	}, stmts)
+
+	// The script runs on SQLite, the column noted to rebuild table and the extra column are left
+	for _, stmt := range stmts {
+		_, err := targetDb.Exec(stmt)
+		as.NoError(err, stmt)
+	}
+	target, err := loadSchemaTables(targetDb, sqliteDialect{}, "target.sqlite")
+	as.NoError(err)
+	diffs, _ := diffSchemaTables(sqliteDialect{}, base, target, []string{"a", "b"})
+	as.Len(diffs, 3)
+}
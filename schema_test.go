@@ -0,0 +1,33 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSchemaTablesSqlite(t *testing.T) {
+	as := assert.New(t)
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "s.sqlite"))
+	as.NoError(err)
+	defer db.Close()
+	_, err = db.Exec(`create table o (id integer primary key autoincrement, name text not null default 'it''s', n int);
+create table p (a int, b int, c text, primary key (b, a));
+create unique index p_c on p (c, a)`)
+	as.NoError(err)
+
+	tables, err := loadSchemaTables(db, sqliteDialect{}, "s")
+	as.NoError(err)
+	as.Len(tables, 2)
+	o := tables["o"]
+	as.Equal("auto_increment", o.Column("id").Extra)
+	as.False(o.Column("name").Nullable)
+	as.Equal("it's", *o.Column("name").Default)
+	as.True(o.Column("n").Nullable)
+	as.Nil(o.Column("n").Default)
+	p := tables["p"]
+	as.Equal([]string{"b", "a"}, p.Index("PRIMARY").Columns)
+	as.True(p.Index("p_c").Unique)
+	as.Equal([]string{"c", "a"}, p.Index("p_c").Columns)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sqler/pkg"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// SearchJob finds the tables and columns whose names or comments contain the text in the metas
+// of all datasources
+type SearchJob struct {
+	sqler *Sqler
+	text  string
+	*BaseJob
+}
+
+func NewSearchJob(sqler *Sqler, text string) Job {
+	return &SearchJob{
+		sqler:   sqler,
+		text:    text,
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *SearchJob) Exec() {
+	tables, columns := searchMetas(job.sqler.tableMetas, job.sqler.columnMeats, job.text)
+	b := new(bytes.Buffer)
+	table := tablewriter.NewWriter(b)
+	table.SetHeader([]string{"Name", "Type", "Comment"})
+	table.SetAutoFormatHeaders(false)
+	for _, tm := range tables {
+		table.Append([]string{tm.Name, "[table]", tm.Comment})
+	}
+	for _, cm := range columns {
+		table.Append([]string{cm.Table + "." + cm.Name, cm.Type, cm.Comment})
+	}
+	table.Render()
+	job.PrintAfterDone(b.String())
+	job.PrintAfterDone(fmt.Sprintf("[%s] Found %d tables and %d columns matching %s",
+		pkg.Now(), len(tables), len(columns), job.text))
+}
+
+// searchMetas returns the tables and columns whose names or comments contain text case-insensitively
+func searchMetas(tms []*TableMeta, cms []*ColumnMeta, text string) ([]*TableMeta, []*ColumnMeta) {
+	text = strings.ToLower(text)
+	match := func(values ...string) bool {
+		for _, v := range values {
+			if strings.Contains(strings.ToLower(v), text) {
+				return true
+			}
+		}
+		return false
+	}
+	tables := make([]*TableMeta, 0)
+	for _, tm := range tms {
+		if match(tm.Name, tm.Comment) {
+			tables = append(tables, tm)
+		}
+	}
+	columns := make([]*ColumnMeta, 0)
+	for _, cm := range cms {
+		if match(cm.Name, cm.Comment) {
+			columns = append(columns, cm)
+		}
+	}
+	return tables, columns
+}
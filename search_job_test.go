@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSearchMetas(t *testing.T) {
+	as := assert.New(t)
+	tms := []*TableMeta{{Name: "sys_user", Comment: "用户表"}, {Name: "sys_role", Comment: "角色表"}}
+	cms := []*ColumnMeta{
+		{Table: "sys_user", Name: "user_name", Comment: "用户名", Type: "varchar(64)"},
+		{Table: "sys_role", Name: "role_name", Comment: "角色名", Type: "varchar(64)"},
+		{Table: "sys_role", Name: "creator", Comment: "创建用户", Type: "bigint"},
+	}
+	tables, columns := searchMetas(tms, cms, "用户")
+	as.Equal([]*TableMeta{tms[0]}, tables)
+	as.Equal([]*ColumnMeta{cms[0], cms[2]}, columns)
+
+	tables, columns = searchMetas(tms, cms, "ROLE")
+	as.Equal([]*TableMeta{tms[1]}, tables)
+	as.Equal([]*ColumnMeta{cms[1]}, columns)
+}
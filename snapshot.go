@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqlQueryer is implemented by *sql.DB and *consistentSnapshot
+type sqlQueryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// consistentSnapshot is a read only transaction on one connection, the transactions of all
+// datasources are started together so that the reads see the data at the same moment
+type consistentSnapshot struct {
+	// A connection runs one query at a time, see queryAsString
+	sync.Mutex
+	conn     *sql.Conn
+	OpenedAt time.Time
+}
+
+func (s *consistentSnapshot) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (s *consistentSnapshot) Close() error {
+	_, err := s.conn.ExecContext(context.Background(), "ROLLBACK")
+	return errors.Join(err, s.conn.Close())
+}
+
+// startSnapshotStmts start a transaction which reads from a snapshot taken right now
+var startSnapshotStmts = map[string][]string{
+	"mysql": {"START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY"},
+	// A deferred transaction takes the snapshot at the first read
+	"sqlite3": {"BEGIN", "SELECT count(*) FROM sqlite_master"},
+}
+
+// readKeywords start the statements which only return rows
+var readKeywords = []string{"SELECT", "WITH", "SHOW", "DESC", "DESCRIBE", "EXPLAIN", "VALUES", "TABLE"}
+
+// writeKeywords are the statements which may follow the common table expressions of WITH
+var writeKeywords = []string{"INSERT", "UPDATE", "DELETE", "REPLACE"}
+
+// isReadStmt returns true if the first keyword of stmt is one of readKeywords, and WITH is not
+// followed by writeKeywords
+func isReadStmt(stmt string) bool {
+	keyword := firstKeyword(stmt)
+	if !slices.ContainsFunc(readKeywords, func(readKeyword string) bool {
+		return strings.EqualFold(keyword, readKeyword)
+	}) {
+		return false
+	}
+	if !strings.EqualFold(keyword, "WITH") {
+		return true
+	}
+	words := strings.FieldsFunc(stmt, func(r rune) bool {
+		return r < 0x80 && !isIdentByte(byte(r))
+	})
+	return !slices.ContainsFunc(words, func(word string) bool {
+		return slices.ContainsFunc(writeKeywords, func(writeKeyword string) bool {
+			return strings.EqualFold(word, writeKeyword)
+		})
+	})
+}
+
+// OpenSnapshots takes the connections of all datasources first, then starts the snapshot
+// transactions at the same time, the reads of Reader use the snapshots until CloseSnapshots
+func (s *Sqler) OpenSnapshots() error {
+	if s.snapshots != nil {
+		return errors.New("consistent snapshots are already open")
+	}
+	snapshots := make([]*consistentSnapshot, len(s.dbs))
+	errs := make([]error, len(s.dbs))
+	ready := new(sync.WaitGroup)
+	done := new(sync.WaitGroup)
+	start := make(chan struct{})
+	for dbIdx, db := range s.dbs {
+		ds := s.cfg.DataSources[dbIdx]
+		ready.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			conn, err := db.Conn(s.ctx)
+			ready.Done()
+			if err != nil {
+				errs[dbIdx] = err
+				return
+			}
+			snapshot := &consistentSnapshot{conn: conn}
+			snapshots[dbIdx] = snapshot
+			// Barrier
+			<-start
+			stmts, ok := startSnapshotStmts[ds.Type]
+			if !ok {
+				errs[dbIdx] = fmt.Errorf("consistent snapshot of %s is not supported", ds.Type)
+				return
+			}
+			snapshot.OpenedAt = time.Now()
+			for _, stmt := range stmts {
+				if _, err := conn.ExecContext(s.ctx, stmt); err != nil {
+					errs[dbIdx] = fmt.Errorf("failed to open snapshot of %s: %w", ds.DsKey(), err)
+					return
+				}
+			}
+		}()
+	}
+	ready.Wait()
+	close(start)
+	done.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		for _, snapshot := range snapshots {
+			if snapshot != nil {
+				_ = snapshot.Close()
+			}
+		}
+		return err
+	}
+	s.snapshots = snapshots
+	return nil
+}
+
+func (s *Sqler) CloseSnapshots() error {
+	errs := make([]error, 0)
+	for _, snapshot := range s.snapshots {
+		errs = append(errs, snapshot.Close())
+	}
+	s.snapshots = nil
+	return errors.Join(errs...)
+}
+
+// Reader returns the snapshot of datasource if open, otherwise the db
+func (s *Sqler) Reader(dbIdx int) sqlQueryer {
+	if s.snapshots != nil {
+		return s.snapshots[dbIdx]
+	}
+	return s.dbs[dbIdx]
+}
+
+// SnapshotAt returns the time when the snapshot of datasource is opened, empty if not open
+func (s *Sqler) SnapshotAt(dbIdx int) string {
+	if s.snapshots == nil {
+		return ""
+	}
+	return s.snapshots[dbIdx].OpenedAt.Format("2006-01-02T15:04:05.000000")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sqler/pkg"
+	"testing"
+)
+
+func TestOpenSnapshots(t *testing.T) {
+	as := assert.New(t)
+	cfg := pkg.NewConfig()
+	s := &Sqler{ctx: context.Background(), cfg: cfg}
+	for _, schema := range []string{"s0", "s1"} {
+		cfg.AddDataSource(&pkg.DataSourceConfig{Type: "sqlite3", Schema: schema})
+		db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), schema+".sqlite"))
+		as.NoError(err)
+		_, err = db.Exec("create table t (id integer)")
+		as.NoError(err)
+		s.dbs = append(s.dbs, db)
+	}
+
+	as.NoError(s.OpenSnapshots())
+	as.Error(s.OpenSnapshots())
+	as.NotEmpty(s.SnapshotAt(1))
+	_, rows, err := queryAsString(s.Reader(0), "select count(*) from t")
+	as.NoError(err)
+	as.Equal("0", rows[0][0])
+	as.NoError(s.CloseSnapshots())
+	as.Equal(s.dbs[0], s.Reader(0))
+	as.Empty(s.SnapshotAt(1))
+}
+
+func TestIsReadStmt(t *testing.T) {
+	as := assert.New(t)
+	as.True(isReadStmt("/* export */ select updated from a"))
+	as.True(isReadStmt("with t as (select 1) select * from t"))
+	as.False(isReadStmt("with t as (select 1) delete from a where id in (select * from t)"))
+	as.False(isReadStmt("insert into a(id) values (9)"))
+	as.False(isReadStmt("update a set a1 = 'select'"))
+
+	flagConsistent = true
+	defer func() { flagConsistent = false }()
+	as.Nil(checkConsistentStmts([]string{"select 1", "show tables"}))
+	as.NotNil(checkConsistentStmts([]string{"select 1", "insert into a(id) values (9)"}))
+}
@@ -1,4 +1,4 @@
-select COLUMN_NAME, COLUMN_COMMENT, COLUMN_TYPE
+select TABLE_NAME, COLUMN_NAME, COLUMN_COMMENT, COLUMN_TYPE
 from information_schema.COLUMNS
 where TABLE_SCHEMA = ? or TABLE_SCHEMA = 'information_schema'
-group by COLUMN_NAME, COLUMN_COMMENT, COLUMN_TYPE;
+order by TABLE_NAME, ORDINAL_POSITION;
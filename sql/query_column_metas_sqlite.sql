@@ -0,0 +1,4 @@
+select t.name, c.name, '', c.type
+from sqlite_master t, pragma_table_info(t.name) c
+where t.type = 'table' and t.name not like 'sqlite_%'
+order by t.name, c.cid
\ No newline at end of file
@@ -0,0 +1,4 @@
+select TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA, COLUMN_COMMENT
+from information_schema.COLUMNS
+where TABLE_SCHEMA = ?
+order by TABLE_NAME, ORDINAL_POSITION;
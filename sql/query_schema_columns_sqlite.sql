@@ -0,0 +1,10 @@
+select t.name, c.name, c.type,
+       case when c."notnull" or c.pk > 0 then 'NO' else 'YES' end,
+       case when upper(c.dflt_value) = 'NULL' then null
+            when c.dflt_value like '''%''' then replace(substr(c.dflt_value, 2, length(c.dflt_value) - 2), '''''', '''')
+            else c.dflt_value end,
+       case when c.pk > 0 and upper(t.sql) like '%AUTOINCREMENT%' then 'auto_increment' else '' end,
+       ''
+from sqlite_master t, pragma_table_info(t.name) c
+where t.type = 'table' and t.name not like 'sqlite_%'
+order by t.name, c.cid
\ No newline at end of file
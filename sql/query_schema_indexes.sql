@@ -0,0 +1,4 @@
+select TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME, INDEX_TYPE
+from information_schema.STATISTICS
+where TABLE_SCHEMA = ?
+order by TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX;
@@ -0,0 +1,9 @@
+select tbl, idx, non_unique, col, ''
+from (select t.name tbl, 'PRIMARY' idx, 0 non_unique, c.name col, c.pk seq
+      from sqlite_master t, pragma_table_info(t.name) c
+      where t.type = 'table' and t.name not like 'sqlite_%' and c.pk > 0
+      union all
+      select t.name, i.name, not i."unique", c.name, c.seqno
+      from sqlite_master t, pragma_index_list(t.name) i, pragma_index_info(i.name) c
+      where t.type = 'table' and t.name not like 'sqlite_%' and i.origin <> 'pk')
+order by tbl, idx, seq
\ No newline at end of file
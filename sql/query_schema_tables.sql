@@ -0,0 +1,4 @@
+select TABLE_NAME, ifnull(ENGINE, ''), ifnull(TABLE_COLLATION, ''), TABLE_COMMENT, ifnull(TABLE_ROWS, 0), ifnull(DATA_LENGTH + INDEX_LENGTH, 0)
+from information_schema.TABLES
+where TABLE_SCHEMA = ? and TABLE_TYPE = 'BASE TABLE'
+order by TABLE_NAME;
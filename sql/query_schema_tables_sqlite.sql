@@ -0,0 +1,4 @@
+select name, '', '', '', 0, 0
+from sqlite_master
+where type = 'table' and name not like 'sqlite_%'
+order by name
\ No newline at end of file
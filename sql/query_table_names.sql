@@ -0,0 +1,4 @@
+select TABLE_NAME
+from information_schema.TABLES
+where TABLE_SCHEMA = ? and TABLE_TYPE = 'BASE TABLE'
+order by TABLE_NAME;
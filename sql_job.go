@@ -9,6 +9,7 @@ import (
 	"sqler/pkg"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,7 +17,7 @@ var _ Job = (*SqlJob)(nil)
 
 type SqlJob struct {
 	Stmt              string
-	DB                *sql.DB
+	DB                sqlQueryer
 	DsCfg             *pkg.DataSourceConfig
 	Prefix            string
 	SqlRows           *sql.Rows
@@ -25,9 +26,9 @@ type SqlJob struct {
 	*BaseJob
 }
 
-func NewSqlJob(stmt string, jobId int, totalJobSize int, dsCfg *pkg.DataSourceConfig, db *sql.DB, jobCtx *JobCtx) Job {
+func NewSqlJob(stmt string, jobId int, totalJobSize int, dsCfg *pkg.DataSourceConfig, db sqlQueryer, jobCtx *JobCtx) Job {
 	prefix := fmt.Sprintf("[%d/%d] (%s/%s) > %s", jobId, totalJobSize, dsCfg.Url, dsCfg.Schema, stmt)
-	stmt, useVerticalResult := parseStmt(stmt)
+	stmt, useVerticalResult := parseStmt(stmt, dialectOf(dsCfg.Type))
 	return &SqlJob{
 		Stmt:              stmt,
 		DB:                db,
@@ -49,6 +50,10 @@ func (job *SqlJob) BeforeExec() {
 
 func (job *SqlJob) Exec() {
 	var err error
+	if l, ok := job.DB.(sync.Locker); ok {
+		l.Lock()
+		defer l.Unlock()
+	}
 	job.SqlRows, err = job.DB.Query(job.Stmt)
 	time.Sleep(time.Duration(1+rand.Intn(1)) * time.Second)
 	if job.RecordError(err) {
@@ -130,9 +135,17 @@ func (job *SqlJob) exportDataToCsv(headers []string, rows [][]string) {
 	job.ctx.CsvFile.Flush()
 }
 
-func parseStmt(stmt string) (string, bool) {
+// parseStmt trims the \G of vertical result, and writes "explain query" in the plan statement of dialect
+func parseStmt(stmt string, dialect Dialect) (string, bool) {
+	useVerticalResult := false
 	if strings.HasSuffix(stmt, `\G`) {
-		return stmt[:len(stmt)-2], true
+		stmt, useVerticalResult = stmt[:len(stmt)-2], true
+	}
+	// The explain of MySQL shows the bytecode at SQLite, "explain query plan" is left as written
+	fields := strings.Fields(stmt)
+	if len(fields) > 1 && strings.EqualFold(fields[0], "explain") && !strings.EqualFold(fields[1], "query") {
+		_, query, _ := strings.Cut(stmt, fields[0])
+		stmt = dialect.Explain(strings.TrimSpace(query))
 	}
-	return stmt, false
+	return stmt, useVerticalResult
 }
@@ -1,8 +1,11 @@
 package main
 
-import "database/sql"
+import (
+	"database/sql"
+	"sync"
+)
 
-func mustQueryAsString(db *sql.DB, query string, args ...any) ([]string, [][]string) {
+func mustQueryAsString(db sqlQueryer, query string, args ...any) ([]string, [][]string) {
 	heads, results, err := queryAsString(db, query, args...)
 	if err != nil {
 		panic(err)
@@ -10,7 +13,11 @@ func mustQueryAsString(db *sql.DB, query string, args ...any) ([]string, [][]str
 	return heads, results
 }
 
-func queryAsString(db *sql.DB, query string, args ...any) ([]string, [][]string, error) {
+func queryAsString(db sqlQueryer, query string, args ...any) ([]string, [][]string, error) {
+	if l, ok := db.(sync.Locker); ok {
+		l.Lock()
+		defer l.Unlock()
+	}
 	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, nil, err
@@ -18,6 +25,29 @@ func queryAsString(db *sql.DB, query string, args ...any) ([]string, [][]string,
 	return convertSqlResults(rows)
 }
 
+// queryAsStringWithTypes also returns the database type names of columns, e.g. "VARCHAR", "DECIMAL"
+func queryAsStringWithTypes(db sqlQueryer, query string, args ...any) ([]string, []string, [][]string, error) {
+	if l, ok := db.(sync.Locker); ok {
+		l.Lock()
+		defer l.Unlock()
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		_ = rows.Close()
+		return nil, nil, nil, err
+	}
+	types := make([]string, len(colTypes))
+	for i, colType := range colTypes {
+		types[i] = colType.DatabaseTypeName()
+	}
+	columns, lines, err := convertSqlResults(rows)
+	return columns, types, lines, err
+}
+
 func convertSqlResults(rows *sql.Rows) ([]string, [][]string, error) {
 	lines := make([][]string, 0)
 	columns, err := rows.Columns()
@@ -62,3 +92,77 @@ func mustConvertSqlResults(rows *sql.Rows) (columns []string, lines [][]string)
 	}
 	return
 }
+
+// pingDb reconnects db if the connection is lost, the connection of snapshot is kept as it is
+func pingDb(db sqlQueryer) {
+	if d, ok := db.(*sql.DB); ok {
+		_ = d.Ping()
+	}
+}
+
+// queryEachAsString streams the rows of query to fn without loading all rows, NULL is "NULL"
+func queryEachAsString(db sqlQueryer, query string, fn func(row []string) error, args ...any) error {
+	if l, ok := db.(sync.Locker); ok {
+		l.Lock()
+		defer l.Unlock()
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]any, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			if v == nil {
+				row[i] = "NULL"
+			} else {
+				row[i] = string(v)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// queryInChunks calls fn with the rows of query in chunks of batchRow, the chunks are ordered by
+// orderBy if not empty. All rows are queried at once if batchRow is 0. fn is called at least once
+func queryInChunks(db sqlQueryer, dialect Dialect, query string, orderBy string, batchRow int,
+	fn func(columns []string, types []string, rows [][]string) error) error {
+	if batchRow <= 0 {
+		columns, types, rows, err := queryAsStringWithTypes(db, query)
+		if err != nil {
+			return err
+		}
+		return fn(columns, types, rows)
+	}
+	if orderBy != "" {
+		query += " order by " + orderBy
+	}
+	for offset := 0; ; offset += batchRow {
+		columns, types, rows, err := queryAsStringWithTypes(db, dialect.Paging(query, batchRow, offset))
+		if err != nil {
+			return err
+		}
+		if err := fn(columns, types, rows); err != nil {
+			return err
+		}
+		if len(rows) < batchRow {
+			return nil
+		}
+	}
+}
@@ -3,7 +3,12 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"sqler/pkg"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Sqler struct {
@@ -13,6 +18,7 @@ type Sqler struct {
 	tableMetas  []*TableMeta
 	columnMeats []*ColumnMeta
 	jobExecutor *JobExecutor
+	snapshots   []*consistentSnapshot
 }
 
 type TableMeta struct {
@@ -21,6 +27,7 @@ type TableMeta struct {
 }
 
 type ColumnMeta struct {
+	Table   string
 	Name    string
 	Comment string
 	Type    string
@@ -44,6 +51,18 @@ func NewSqler(cfg *pkg.Config) *Sqler {
 	return s
 }
 
+// Close stops the job executor and closes the connections of datasources
+func (s *Sqler) Close() error {
+	s.jobExecutor.Shutdown(false)
+	errs := make([]error, 0, len(s.dbs))
+	for _, db := range s.dbs {
+		if db != nil {
+			errs = append(errs, db.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (s *Sqler) ConnectToDb() {
 	jobExecutor := NewJobExecutor(len(s.dbs))
 	jobExecutor.Start()
@@ -62,7 +81,7 @@ func (s *Sqler) ExecSerial(jobCtx *JobCtx, stmts ...string) {
 		jobCtx.CsvFileHeaderWrote = false
 		for dbId := range s.dbs {
 			jobId++
-			job := NewSqlJob(stmt, jobId, jobSize, s.cfg.DataSources[dbId], s.dbs[dbId], jobCtx)
+			job := NewSqlJob(stmt, jobId, jobSize, s.cfg.DataSources[dbId], s.sqlJobDb(jobCtx, dbId), jobCtx)
 			s.jobExecutor.Submit(job, dbId)
 			s.jobExecutor.WaitForNoRemainJob()
 		}
@@ -77,7 +96,7 @@ func (s *Sqler) ExecPara(jobCtx *JobCtx, stmts ...string) {
 		jobCtx.CsvFileHeaderWrote = false
 		for dbId := range s.dbs {
 			jobId++
-			job := NewSqlJob(stmt, jobId, jobSize, s.cfg.DataSources[dbId], s.dbs[dbId], jobCtx)
+			job := NewSqlJob(stmt, jobId, jobSize, s.cfg.DataSources[dbId], s.sqlJobDb(jobCtx, dbId), jobCtx)
 			s.jobExecutor.Submit(job, dbId)
 		}
 		s.jobExecutor.WaitForNoRemainJob()
@@ -87,55 +106,104 @@ func (s *Sqler) ExecPara(jobCtx *JobCtx, stmts ...string) {
 	}
 }
 
+// sqlJobDb returns the snapshot for exports, other statements which may write are executed on the db
+func (s *Sqler) sqlJobDb(jobCtx *JobCtx, dbId int) sqlQueryer {
+	if jobCtx.ExportCsv {
+		return s.Reader(dbId)
+	}
+	return s.dbs[dbId]
+}
+
 func (s *Sqler) totalStmtSize(stmtSize int) int {
 	return len(s.dbs) * stmtSize
 }
 
-func (s *Sqler) loadSchema() error {
-	db0 := s.dbs[0]
-	schema := s.cfg.DataSources[0].Schema
-
-	tx, err := db0.Begin()
-	if err != nil {
-		return err
+// loadSchema loads the table and column metas of all datasources, the metas cached within ttl
+// are read from disk and 0 reloads all. Tables and columns of the same name are kept once
+func (s *Sqler) loadSchema(ttl time.Duration) error {
+	tms := make([][]*TableMeta, len(s.dbs))
+	cms := make([][]*ColumnMeta, len(s.dbs))
+	errs := make([]error, len(s.dbs))
+	wg := new(sync.WaitGroup)
+	for dbIdx := range s.dbs {
+		ds := s.cfg.DataSources[dbIdx]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cacheFileName := metaCacheFileName(s.cfg.FileName, ds.DsKey())
+			if cache, ok := readMetaCache(cacheFileName, ttl); ok {
+				tms[dbIdx], cms[dbIdx] = cache.Tables, cache.Columns
+				return
+			}
+			cache := &metaCache{DsKey: ds.DsKey(), LoadedAt: time.Now()}
+			var err error
+			if cache.Tables, cache.Columns, err = s.loadMetas(dbIdx); err != nil {
+				errs[dbIdx] = fmt.Errorf("failed to load metas of %s: %w", ds.DsKey(), err)
+				return
+			}
+			tms[dbIdx], cms[dbIdx] = cache.Tables, cache.Columns
+			errs[dbIdx] = writeMetaCache(cacheFileName, cache)
+		}()
 	}
-	qtm, err := tx.Prepare(stmtQueryTableMetas)
-	if err != nil {
-		return err
+	wg.Wait()
+
+	s.tableMetas = make([]*TableMeta, 0, len(tms[0]))
+	s.columnMeats = make([]*ColumnMeta, 0, len(cms[0]))
+	seen := make(map[string]bool)
+	for dbIdx := range s.dbs {
+		for _, tm := range tms[dbIdx] {
+			if key := strings.ToLower(tm.Name); !seen[key] {
+				seen[key] = true
+				s.tableMetas = append(s.tableMetas, tm)
+			}
+		}
+		for _, cm := range cms[dbIdx] {
+			if key := strings.ToLower(cm.Table + "." + cm.Name); !seen[key] {
+				seen[key] = true
+				s.columnMeats = append(s.columnMeats, cm)
+			}
+		}
 	}
-	rows, err := qtm.Query(schema)
+	return errors.Join(errs...)
+}
+
+// loadMetas queries the table and column metas from the catalog of datasource
+func (s *Sqler) loadMetas(dbIdx int) ([]*TableMeta, []*ColumnMeta, error) {
+	db := s.dbs[dbIdx]
+	schema := s.cfg.DataSources[dbIdx].Schema
+	dialect := s.Dialect(dbIdx)
+
+	tms := make([]*TableMeta, 0, 32)
+	query, args := dialect.TableMetasQuery(schema)
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	for rows.Next() {
 		tm := &TableMeta{}
 		if err := rows.Scan(&tm.Name, &tm.Comment); err != nil {
-			return err
+			_ = rows.Close()
+			return nil, nil, err
 		}
-		s.tableMetas = append(s.tableMetas, tm)
+		tms = append(tms, tm)
 	}
-	defer qtm.Close()
-
-	tx, err = db0.Begin()
-	if err != nil {
-		return err
-	}
-	qcm, err := tx.Prepare(stmtQueryColumnMetas)
-	if err != nil {
-		return err
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
-	rows, err = qcm.Query(schema)
+
+	cms := make([]*ColumnMeta, 0, 128)
+	query, args = dialect.ColumnMetasQuery(schema)
+	rows, err = db.Query(query, args...)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	for rows.Next() {
 		cm := &ColumnMeta{}
-		if err := rows.Scan(&cm.Name, &cm.Comment, &cm.Type); err != nil {
-			return err
+		if err := rows.Scan(&cm.Table, &cm.Name, &cm.Comment, &cm.Type); err != nil {
+			_ = rows.Close()
+			return nil, nil, err
 		}
-		s.columnMeats = append(s.columnMeats, cm)
+		cms = append(cms, cm)
 	}
-	defer qcm.Close()
-
-	return nil
+	return tms, cms, rows.Err()
 }
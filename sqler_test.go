@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sqler/pkg"
+	"testing"
+)
+
+// newFixtureSqler connects to the copies of the bundled sqlite datasources, the working directory
+// is changed to a temp directory holding the copies until the test ends
+func newFixtureSqler(t *testing.T) *Sqler {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"db_base.sqlite", "db_01.sqlite", "db_02.sqlite", "config-sqlite.yml"} {
+		data, err := os.ReadFile(filepath.Join(wd, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := pkg.LoadConfigFromFile("config-sqlite.yml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initJobPrinter(false)
+	s := NewSqler(cfg)
+	t.Cleanup(func() {
+		s.Close()
+		os.Chdir(wd)
+	})
+	return s
+}
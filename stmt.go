@@ -8,4 +8,34 @@ var (
 
 	//go:embed sql/query_column_metas.sql
 	stmtQueryColumnMetas string
+
+	//go:embed sql/query_table_metas_sqlite.sql
+	stmtQueryTableMetasSqlite string
+
+	//go:embed sql/query_column_metas_sqlite.sql
+	stmtQueryColumnMetasSqlite string
+
+	//go:embed sql/query_schema_tables.sql
+	stmtQuerySchemaTables string
+
+	//go:embed sql/query_schema_columns.sql
+	stmtQuerySchemaColumns string
+
+	//go:embed sql/query_schema_indexes.sql
+	stmtQuerySchemaIndexes string
+
+	//go:embed sql/query_schema_tables_sqlite.sql
+	stmtQuerySchemaTablesSqlite string
+
+	//go:embed sql/query_schema_columns_sqlite.sql
+	stmtQuerySchemaColumnsSqlite string
+
+	//go:embed sql/query_schema_indexes_sqlite.sql
+	stmtQuerySchemaIndexesSqlite string
+
+	//go:embed sql/query_table_names.sql
+	stmtQueryTableNames string
+
+	//go:embed sql/query_table_names_sqlite.sql
+	stmtQueryTableNamesSqlite string
 )
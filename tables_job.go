@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"sqler/pkg"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// TablesJob lists the tables of all datasources with comments, row estimates and sizes, the
+// datasources which do not have a table or have another comment are flagged
+type TablesJob struct {
+	sqler   *Sqler
+	pattern string
+	*BaseJob
+}
+
+func NewTablesJob(sqler *Sqler, pattern string) Job {
+	return &TablesJob{
+		sqler:   sqler,
+		pattern: pattern,
+		BaseJob: NewBaseJob(new(JobCtx)),
+	}
+}
+
+func (job *TablesJob) Exec() {
+	// The datasources failed to load are flagged with ERROR and their errors recorded after printing
+	schemas, loadErr := loadAllSchemaTables(job.sqler)
+	if !anySchemaLoaded(schemas) {
+		job.RecordError(loadErr)
+		return
+	}
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, tables := range schemas {
+		for name := range tables {
+			if !seen[name] && matchTableName(job.pattern, name) {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	b := new(bytes.Buffer)
+	table := tablewriter.NewWriter(b)
+	header := []string{"Table", "Comment"}
+	for _, ds := range job.sqler.cfg.DataSources {
+		header = append(header, ds.Name())
+	}
+	table.SetHeader(append(header, "Mismatch"))
+	table.SetAutoFormatHeaders(false)
+	mismatchTables := 0
+	for _, name := range names {
+		// The first datasource which has the table is the base
+		var base *SchemaTable
+		for _, tables := range schemas {
+			if base = tables[name]; base != nil {
+				break
+			}
+		}
+		row := []string{name, base.Comment}
+		mismatch := make([]string, 0)
+		for dbIdx, tables := range schemas {
+			t := tables[name]
+			switch {
+			case tables == nil:
+				row = append(row, countError+" *")
+			case t == nil:
+				row = append(row, countAbsent+" *")
+			case t.Comment != base.Comment:
+				row = append(row, fmt.Sprintf("%d rows, %s, comment: %s *", t.RowsEst, byteSize(t.Size), t.Comment))
+			default:
+				row = append(row, fmt.Sprintf("%d rows, %s", t.RowsEst, byteSize(t.Size)))
+				continue
+			}
+			mismatch = append(mismatch, job.sqler.cfg.DataSources[dbIdx].Name())
+		}
+		if len(mismatch) > 0 {
+			mismatchTables++
+		}
+		table.Append(append(row, strings.Join(mismatch, " ")))
+	}
+	table.Render()
+	job.PrintAfterDone(b.String())
+	job.PrintAfterDone(fmt.Sprintf("[%s] Listed %d tables, %d tables are absent or differ in comment (*)",
+		pkg.Now(), len(names), mismatchTables))
+	job.RecordError(loadErr)
+}
+
+// matchTableName matches name by pattern case-insensitively, pattern with * or % matches the
+// whole name, otherwise a part of name. Empty pattern matches all names
+func matchTableName(pattern string, name string) bool {
+	pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	if !strings.ContainsAny(pattern, "*%") {
+		return strings.Contains(name, pattern)
+	}
+	matched, _ := path.Match(strings.ReplaceAll(pattern, "%", "*"), name)
+	return matched
+}
+
+// byteSize formats size in B, KB, MB, GB or TB
+func byteSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatInt(size, 10) + units[0]
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + units[unit]
+}
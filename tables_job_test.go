@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatchTableName(t *testing.T) {
+	as := assert.New(t)
+	as.True(matchTableName("", "sys_user"))
+	as.True(matchTableName("USER", "sys_user"))
+	as.True(matchTableName("sys_*", "sys_user"))
+	as.True(matchTableName("%user", "sys_user"))
+	as.False(matchTableName("user*", "sys_user"))
+	as.Equal("512B", byteSize(512))
+	as.Equal("1.5KB", byteSize(1536))
+	as.Equal("2.0GB", byteSize(2<<30))
+}